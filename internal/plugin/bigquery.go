@@ -0,0 +1,203 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/cortea-ai/protoc-gen-pydantic/internal/protowalk"
+	"github.com/cortea-ai/protoc-gen-pydantic/validate"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// bigQueryProfile is the serialization profile the rows of BigQuery tables
+// are dumped with, deciding how maps, enums and timestamps are typed.
+const bigQueryProfile = "bigquery"
+
+// bigQueryField is a column of a BigQuery JSON table schema.
+type bigQueryField struct {
+	Name        string          `json:"name"`
+	Type        string          `json:"type"`
+	Mode        string          `json:"mode"`
+	Description string          `json:"description,omitempty"`
+	Fields      []bigQueryField `json:"fields,omitempty"`
+}
+
+// bigQueryTable is the schema of a message marked with
+// (py_validate.bigquery_table).
+type bigQueryTable struct {
+	message protoreflect.MessageDescriptor
+	fields  []bigQueryField
+}
+
+// schema returns the table schema as written to <Message>.schema.json.
+func (b bigQueryTable) schema() ([]byte, error) {
+	content, err := json.MarshalIndent(b.fields, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshal BigQuery schema of %s: %w", b.message.FullName(), err)
+	}
+	return append(content, '\n'), nil
+}
+
+// packageBigQueryTables collects the schemas of the messages of a package
+// marked as BigQuery tables.
+func packageBigQueryTables(pkg protoreflect.FullName, files []protoreflect.FileDescriptor, profiles serializationProfiles, selected selection) ([]bigQueryTable, error) {
+	var transforms []string
+	for _, profile := range profiles {
+		if profile.name == bigQueryProfile {
+			transforms = profile.transforms
+		}
+	}
+	s := bigQuerySchema{transforms: transforms}
+	var tables []bigQueryTable
+	var err error
+	protowalk.WalkFiles(files, func(desc protoreflect.Descriptor) bool {
+		message, ok := desc.(protoreflect.MessageDescriptor)
+		if !ok || message.ParentFile().Package() != pkg || !selected.has(message) {
+			return true
+		}
+		if !proto.GetExtension(message.Options(), validate.E_BigqueryTable).(bool) {
+			return true
+		}
+		var fields []bigQueryField
+		fields, err = s.fields(message, nil)
+		if err != nil {
+			return false
+		}
+		tables = append(tables, bigQueryTable{message: message, fields: fields})
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tables, nil
+}
+
+type bigQuerySchema struct {
+	transforms []string
+}
+
+func (s bigQuerySchema) uses(transform string) bool {
+	for _, t := range s.transforms {
+		if t == transform {
+			return true
+		}
+	}
+	return false
+}
+
+// fields returns the columns of a message, with stack holding the messages
+// already being expanded as RECORDs.
+func (s bigQuerySchema) fields(message protoreflect.MessageDescriptor, stack []protoreflect.FullName) ([]bigQueryField, error) {
+	for _, name := range stack {
+		if name == message.FullName() {
+			return nil, fmt.Errorf("BigQuery schema: %s is recursive and cannot be a RECORD", message.FullName())
+		}
+	}
+	stack = append(stack, message.FullName())
+	fields := make([]bigQueryField, 0, message.Fields().Len())
+	for i := 0; i < message.Fields().Len(); i++ {
+		field := message.Fields().Get(i)
+		if hasFieldBehavior(field, annotations.FieldBehavior_INPUT_ONLY) {
+			// excluded from dumped rows
+			continue
+		}
+		column, err := s.field(field, stack)
+		if err != nil {
+			return nil, err
+		}
+		column.Description = commentGenerator{descriptor: field}.text()
+		fields = append(fields, column)
+	}
+	return fields, nil
+}
+
+func (s bigQuerySchema) field(field protoreflect.FieldDescriptor, stack []protoreflect.FullName) (bigQueryField, error) {
+	column := bigQueryField{Name: string(field.Name()), Mode: "NULLABLE"}
+	switch {
+	case field.IsMap() && !s.uses(transformMapsAsRecords):
+		column.Type = "JSON"
+		return column, nil
+	case field.IsMap():
+		key, err := s.field(field.MapKey(), stack)
+		if err != nil {
+			return column, err
+		}
+		value, err := s.field(field.MapValue(), stack)
+		if err != nil {
+			return column, err
+		}
+		column.Type = "RECORD"
+		column.Mode = "REPEATED"
+		column.Fields = []bigQueryField{key, value}
+		return column, nil
+	case field.IsList():
+		column.Mode = "REPEATED"
+	case field.Cardinality() == protoreflect.Required:
+		column.Mode = "REQUIRED"
+	case hasFieldBehavior(field, annotations.FieldBehavior_REQUIRED) && !isOneOfField(field):
+		column.Mode = "REQUIRED"
+	}
+	var err error
+	column.Type, column.Fields, err = s.fieldType(field, stack)
+	return column, err
+}
+
+func (s bigQuerySchema) fieldType(field protoreflect.FieldDescriptor, stack []protoreflect.FullName) (string, []bigQueryField, error) {
+	switch field.Kind() {
+	case protoreflect.StringKind, protoreflect.BytesKind:
+		// models hold bytes as str and dump them as text, which a BYTES
+		// column would read as base64
+		return "STRING", nil, nil
+	case protoreflect.BoolKind:
+		return "BOOLEAN", nil, nil
+	case
+		protoreflect.Int32Kind,
+		protoreflect.Int64Kind,
+		protoreflect.Uint32Kind,
+		protoreflect.Uint64Kind,
+		protoreflect.Fixed32Kind,
+		protoreflect.Fixed64Kind,
+		protoreflect.Sfixed32Kind,
+		protoreflect.Sfixed64Kind,
+		protoreflect.Sint32Kind,
+		protoreflect.Sint64Kind:
+		return "INTEGER", nil, nil
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return "FLOAT", nil, nil
+	case protoreflect.EnumKind:
+		if s.uses(transformEnumsAsInt) {
+			return "INTEGER", nil, nil
+		}
+		return "STRING", nil, nil
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		if wkt, ok := WellKnownType(field.Message()); ok {
+			switch wkt {
+			case WellKnownTimestamp:
+				return "TIMESTAMP", nil, nil
+			case WellKnownDuration:
+				if s.uses(transformTimestampsAsEpoch) {
+					return "FLOAT", nil, nil
+				}
+				// dumped as an ISO 8601 duration
+				return "STRING", nil, nil
+			default:
+				return "", nil, fmt.Errorf("BigQuery schema: unsupported well known type %s of %s", wkt, field.FullName())
+			}
+		}
+		fields, err := s.fields(field.Message(), stack)
+		return "RECORD", fields, err
+	default:
+		return "", nil, fmt.Errorf("BigQuery schema: unknown field kind %s of %s", field.Kind(), field.FullName())
+	}
+}
+
+func hasFieldBehavior(field protoreflect.FieldDescriptor, behavior annotations.FieldBehavior) bool {
+	for _, b := range getFieldBehaviors(field) {
+		if b == behavior {
+			return true
+		}
+	}
+	return false
+}
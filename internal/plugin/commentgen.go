@@ -14,13 +14,12 @@ type commentGenerator struct {
 }
 
 func (c commentGenerator) generateLeading(f *codegen.File, indent int) {
-	loc := c.descriptor.ParentFile().SourceLocations().ByDescriptor(c.descriptor)
-	lines := strings.Split(loc.LeadingComments, "\n")
+	lines := strings.Split(c.text(), "\n")
 	for _, line := range lines {
 		if line == "" {
 			continue
 		}
-		f.P(t(indent), "# ", strings.TrimSpace(line))
+		f.P(t(indent), "# ", line)
 	}
 	if field, ok := c.descriptor.(protoreflect.FieldDescriptor); ok {
 		if behaviorComment := fieldBehaviorComment(field); len(behaviorComment) > 0 {
@@ -30,6 +29,62 @@ func (c commentGenerator) generateLeading(f *codegen.File, indent int) {
 	}
 }
 
+// generateBehaviors emits only the field behaviors of a field, for when its
+// comments are carried by the field description instead.
+func (c commentGenerator) generateBehaviors(f *codegen.File, indent int) {
+	if field, ok := c.descriptor.(protoreflect.FieldDescriptor); ok {
+		if behaviorComment := fieldBehaviorComment(field); len(behaviorComment) > 0 {
+			f.P(t(indent), "# ", behaviorComment)
+		}
+	}
+}
+
+func (c commentGenerator) generateDocstring(f *codegen.File, indent int) {
+	generateDocstring(f, indent, c.text())
+}
+
+// generateDocstring emits text as a docstring, escaping what would end it.
+func generateDocstring(f *codegen.File, indent int, text string) {
+	if text == "" {
+		return
+	}
+	text = strings.ReplaceAll(text, `\`, `\\`)
+	text = strings.ReplaceAll(text, `"""`, `\"\"\"`)
+	if strings.HasSuffix(text, `"`) {
+		text = strings.TrimSuffix(text, `"`) + `\"`
+	}
+	lines := strings.Split(text, "\n")
+	if len(lines) == 1 {
+		f.P(t(indent), `"""`, text, `"""`)
+		return
+	}
+	f.P(t(indent), `"""`, lines[0])
+	for _, line := range lines[1:] {
+		if line == "" {
+			f.P()
+		} else {
+			f.P(t(indent), line)
+		}
+	}
+	f.P(t(indent), `"""`)
+}
+
+// text returns the leading and trailing comments with each line trimmed.
+func (c commentGenerator) text() string {
+	loc := c.descriptor.ParentFile().SourceLocations().ByDescriptor(c.descriptor)
+	lines := make([]string, 0)
+	for _, comment := range []string{loc.LeadingComments, loc.TrailingComments} {
+		comment = strings.TrimSpace(comment)
+		if comment == "" {
+			continue
+		}
+		for _, line := range strings.Split(comment, "\n") {
+			lines = append(lines, strings.TrimSpace(line))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
 func fieldBehaviorComment(field protoreflect.FieldDescriptor) string {
 	behaviors := getFieldBehaviors(field)
 	if len(behaviors) == 0 {
@@ -43,6 +98,15 @@ func fieldBehaviorComment(field protoreflect.FieldDescriptor) string {
 	return "Behaviors: " + strings.Join(behaviorStrings, ", ")
 }
 
+func hasFieldBehaviors(message protoreflect.MessageDescriptor) bool {
+	for i := 0; i < message.Fields().Len(); i++ {
+		if len(getFieldBehaviors(message.Fields().Get(i))) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
 func getFieldBehaviors(field protoreflect.FieldDescriptor) []annotations.FieldBehavior {
 	if behaviors, ok := proto.GetExtension(
 		field.Options(), annotations.E_FieldBehavior,
@@ -1,21 +1,28 @@
 package plugin
 
 import (
+	"bytes"
 	"fmt"
 	"strconv"
 	"strings"
 
 	"github.com/cortea-ai/protoc-gen-pydantic/internal/codegen"
-	"github.com/cortea-ai/protoc-gen-pydantic/validate"
-	"google.golang.org/protobuf/proto"
+	"google.golang.org/genproto/googleapis/api/annotations"
 	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
 )
 
 type descriptorGenerator struct {
-	name   string
-	pkg    protoreflect.FullName
-	desc   protoreflect.Descriptor
-	indent int
+	name      string
+	pkg       protoreflect.FullName
+	desc      protoreflect.Descriptor
+	indent    int
+	params    map[string]string
+	types     *protoregistry.Types
+	resources resourceNames
+	profiles  serializationProfiles
+	target    pythonTarget
 }
 
 func (d descriptorGenerator) GenerateHeader(f *codegen.File) {
@@ -39,18 +46,115 @@ func (d descriptorGenerator) GenerateFields(f *codegen.File) {
 }
 
 func (d descriptorGenerator) generateEnumHeader(f *codegen.File) {
-	f.P(t(d.indent), "class ", d.name, "(StrEnum):")
+	d.generateClassHeader(f, "ProtoEnum")
 }
 
 func (d descriptorGenerator) generateMessageHeader(f *codegen.File) {
-	f.P(t(d.indent), "class ", d.name, "(BaseModel):")
+	d.generateClassHeader(f, "BaseModel")
+}
+
+// generateClassHeader declares the class of the descriptor with its comments,
+// as "#" lines above it or as its docstring.
+func (d descriptorGenerator) generateClassHeader(f *codegen.File, base string) {
+	if d.hashComments() {
+		commentGenerator{descriptor: d.desc}.generateLeading(f, d.indent)
+	}
+	f.P(t(d.indent), "class ", d.name, "(", base, "):")
+	if !d.hashComments() {
+		commentGenerator{descriptor: d.desc}.generateDocstring(f, d.indent+2)
+	}
+}
+
+// hashComments reports whether comments are emitted as "#" lines rather than
+// docstrings and field descriptions.
+func (d descriptorGenerator) hashComments() bool {
+	return d.params["comment_style"] == "hash"
 }
 
 func (d descriptorGenerator) generateEnumFields(f *codegen.File, enum protoreflect.EnumDescriptor) {
+	dropUnspecified := boolParam(d.params, "drop_unspecified")
+	hasAliases := false
 	rangeEnumValues(enum, func(value protoreflect.EnumValueDescriptor, last bool) {
-		commentGenerator{descriptor: value}.generateLeading(f, d.indent+2)
+		if dropUnspecified && isUnspecifiedValue(value) {
+			return
+		}
+		if d.hashComments() {
+			commentGenerator{descriptor: value}.generateLeading(f, d.indent+2)
+		}
+		if primary := primaryEnumValue(value, dropUnspecified); primary != value {
+			// allow_alias duplicates resolve to the first value with the same
+			// number, which makes them Python enum aliases.
+			f.P(t(d.indent+2), string(value.Name()), " = ", string(primary.Name()))
+			hasAliases = true
+			return
+		}
 		f.P(t(d.indent+2), string(value.Name()), " = ", strconv.Quote(string(value.Name())))
 	})
+	d.generateEnumValueMetadata(f, enum, dropUnspecified)
+	if enum.IsClosed() {
+		// closed enums (proto2, or the CLOSED enum_type feature) reject
+		// unknown numbers instead of preserving them
+		f.P(t(d.indent+2), "__proto_closed__ = True")
+	}
+	d.generateDescriptorAccessors(f)
+
+	reservedNames := make([]string, 0, enum.ReservedNames().Len())
+	for i := 0; i < enum.ReservedNames().Len(); i++ {
+		reservedNames = append(reservedNames, strconv.Quote(string(enum.ReservedNames().Get(i))))
+	}
+	reservedNumbers := make([]string, 0, enum.ReservedRanges().Len())
+	for i := 0; i < enum.ReservedRanges().Len(); i++ {
+		r := enum.ReservedRanges().Get(i)
+		reservedNumbers = append(reservedNumbers, fmt.Sprintf("(%d, %d),", r[0], r[1]))
+	}
+	isReserved := len(reservedNames) > 0 || len(reservedNumbers) > 0
+	if !hasAliases && !isReserved {
+		return
+	}
+	f.P()
+	if isReserved {
+		f.P(t(d.indent+2), "__reserved_names__ = frozenset({", strings.Join(reservedNames, ", "), "})")
+		f.P(t(d.indent+2), "__reserved_numbers__ = (", strings.Join(reservedNumbers, " "), ")")
+		f.P()
+	}
+	f.P(t(d.indent+2), "@classmethod")
+	f.P(t(d.indent+2), "def _missing_(cls, value):")
+	if hasAliases {
+		// member values hold the primary name, so aliases are only found by name
+		f.P(t(d.indent+4), "if value in cls.__members__:")
+		f.P(t(d.indent+6), "return cls.__members__[value]")
+	}
+	if isReserved {
+		f.P(t(d.indent+4), "if value in cls.__reserved_names__ or (")
+		f.P(t(d.indent+6), "isinstance(value, int) and any(lo <= value <= hi for lo, hi in cls.__reserved_numbers__)")
+		f.P(t(d.indent+4), "):")
+		f.P(t(d.indent+6), `raise ValueError(f"{value!r} is reserved in {cls.__name__}")`)
+	}
+	f.P(t(d.indent+4), "return None")
+}
+
+// generateEnumValueMetadata emits the proto number, leading comment,
+// deprecation and custom options of each value, read by ProtoEnum.
+func (d descriptorGenerator) generateEnumValueMetadata(f *codegen.File, enum protoreflect.EnumDescriptor, dropUnspecified bool) {
+	f.P()
+	f.P(t(d.indent+2), "__proto_values__ = {")
+	rangeEnumValues(enum, func(value protoreflect.EnumValueDescriptor, last bool) {
+		if primaryEnumValue(value, dropUnspecified) != value || (dropUnspecified && isUnspecifiedValue(value)) {
+			return
+		}
+		opts := value.Options().(*descriptorpb.EnumValueOptions)
+		deprecated := "False"
+		if opts.GetDeprecated() {
+			deprecated = "True"
+		}
+		f.P(t(d.indent+4), strconv.Quote(string(value.Name())), ": {")
+		f.P(t(d.indent+6), `"number": `, value.Number(), ",")
+		f.P(t(d.indent+6), `"description": `, strconv.Quote(commentGenerator{descriptor: value}.text()), ",")
+		f.P(t(d.indent+6), `"deprecated": `, deprecated, ",")
+		f.P(t(d.indent+6), `"options": {`, strings.Join(customOptions(opts, d.types), ", "), "},")
+		f.P(t(d.indent+4), "},")
+	})
+	f.P(t(d.indent+2), "}")
 }
 
 func (d descriptorGenerator) generateMessageFields(f *codegen.File, message protoreflect.MessageDescriptor) {
@@ -58,166 +162,273 @@ func (d descriptorGenerator) generateMessageFields(f *codegen.File, message prot
 		return
 	}
 
-	mapFields := make([]string, 0)
-	oneofFields := make([]string, 0)
-	rangeFields(message, func(field protoreflect.FieldDescriptor) {
-		defaultValue := ""
-		defaultFactory := ""
-		isUUID := false
-		opts := []string{}
+	fields := d.pydanticFields(message)
+	for _, pf := range fields {
+		d.generateFieldComments(f, pf.field)
+		f.P(t(d.indent+2), pf.declaration(d.target))
+	}
+	d.generateProfileSerializer(f, fields)
+	d.generateUnspecifiedValidators(f, fields)
+	d.generateResourceValidators(f, fields)
+	d.generateOneOfValidator(f, fields)
+	d.generateStrictSchema(f, message)
+	d.generateProtoConversions(f, message)
+	d.generateWireFormat(f, message)
+	d.generateDescriptorAccessors(f)
+	if bytes.HasSuffix(f.Content(), []byte("class "+d.name+"(BaseModel):\n")) {
+		// nothing was declared in the class, e.g. for an empty message
+		f.P(t(d.indent+2), "pass")
+	}
+}
 
-		fieldType := typeFromField(d.pkg, field)
+// GenerateVariants emits the Create and Update input models of a message
+// using google.api.field_behavior, after its top-level message.
+func (d descriptorGenerator) GenerateVariants(f *codegen.File) {
+	message, ok := d.desc.(protoreflect.MessageDescriptor)
+	if !ok || !boolParam(d.params, "input_variants") || !hasFieldBehaviors(message) {
+		return
+	}
+	// a class body does not see the names of the classes enclosing it, so
+	// variants are declared at module level, where qualified names resolve
+	d.indent = 0
 
-		commentGenerator{descriptor: field}.generateLeading(f, d.indent+2)
+	f.P("class ", d.name, "Create(BaseModel):")
+	fields := d.variantFields(createVariantFields(d.pydanticFields(message)))
+	d.generateVariantComment(f, createVariantDescription(d.name), len(fields) == 0)
+	for _, pf := range fields {
+		d.generateFieldComments(f, pf.field)
+		f.P(t(d.indent+2), pf.declaration(d.target))
+	}
+	d.generateUnspecifiedValidators(f, fields)
+	d.generateResourceValidators(f, fields)
+	d.generateOneOfValidator(f, fields)
+	f.P()
+	f.P()
+	d.generateNestedVariant(f, d.name+"Create")
 
-		rules := proto.GetExtension(field.Options(), validate.E_Rules)
-		switch r := rules.(type) {
-		case *validate.FieldRules:
-			if r.GetFloat() != nil {
-				if hasPresence(r.GetFloat().ProtoReflect(), "default") {
-					defaultValue = fmt.Sprintf("default=%.1f", r.GetFloat().GetDefault())
-				}
-				if hasPresence(r.GetFloat().ProtoReflect(), "lt") {
-					opts = append(opts, fmt.Sprintf("lt=%.1f", r.GetFloat().GetLt()))
-				}
-				if hasPresence(r.GetFloat().ProtoReflect(), "gt") {
-					opts = append(opts, fmt.Sprintf("gt=%.1f", r.GetFloat().GetGt()))
-				}
-				if hasPresence(r.GetFloat().ProtoReflect(), "gte") {
-					opts = append(opts, fmt.Sprintf("gte=%.1f", r.GetFloat().GetGte()))
-				}
-				if hasPresence(r.GetFloat().ProtoReflect(), "lte") {
-					opts = append(opts, fmt.Sprintf("lte=%.1f", r.GetFloat().GetLte()))
-				}
-			}
-			if r.GetInt32() != nil {
-				if hasPresence(r.GetInt32().ProtoReflect(), "default") {
-					defaultValue = fmt.Sprintf("default=%d", r.GetInt32().GetDefault())
-				}
-				if hasPresence(r.GetInt32().ProtoReflect(), "lt") {
-					opts = append(opts, fmt.Sprintf("lt=%d", r.GetInt32().GetLt()))
-				}
-				if hasPresence(r.GetInt32().ProtoReflect(), "gt") {
-					opts = append(opts, fmt.Sprintf("gt=%d", r.GetInt32().GetGt()))
-				}
-				if hasPresence(r.GetInt32().ProtoReflect(), "gte") {
-					opts = append(opts, fmt.Sprintf("gte=%d", r.GetInt32().GetGte()))
-				}
-				if hasPresence(r.GetInt32().ProtoReflect(), "lte") {
-					opts = append(opts, fmt.Sprintf("lte=%d", r.GetInt32().GetLte()))
-				}
-			}
-			if r.GetString_() != nil {
-				if hasPresence(r.GetString_().ProtoReflect(), "default") {
-					defaultValue = fmt.Sprintf(`default="%s"`, r.GetString_().GetDefault())
-				}
-				if hasPresence(r.GetString_().ProtoReflect(), "len") {
-					opts = append(opts, fmt.Sprintf("len=%d", r.GetString_().GetLen()))
-				}
-				if hasPresence(r.GetString_().ProtoReflect(), "min_length") {
-					opts = append(opts, fmt.Sprintf("min_length=%d", r.GetString_().GetMinLength()))
-				}
-				if hasPresence(r.GetString_().ProtoReflect(), "max_length") {
-					opts = append(opts, fmt.Sprintf("max_length=%d", r.GetString_().GetMaxLength()))
-				}
-				isUUID = r.GetString_().GetUuid()
-			}
-			if r.GetMessage() != nil {
-				if hasPresence(r.GetMessage().ProtoReflect(), "default_factory") {
-					defaultFactory = fmt.Sprintf(`default_factory="%s"`, r.GetMessage().GetDefaultFactory())
-				}
-				if hasPresence(r.GetMessage().ProtoReflect(), "default_empty") && r.GetMessage().GetDefaultEmpty() {
-					defaultValue = fmt.Sprintf(`default_factory=%s`, fieldType.Reference(isUUID))
-				}
-			}
-			if r.GetRepeated() != nil {
-				if hasPresence(r.GetRepeated().ProtoReflect(), "len") {
-					opts = append(opts, fmt.Sprintf("len=%d", r.GetRepeated().GetLen()))
-				}
-				if hasPresence(r.GetRepeated().ProtoReflect(), "min_length") {
-					opts = append(opts, fmt.Sprintf("min_length=%d", r.GetRepeated().GetMinLength()))
-				}
-				if hasPresence(r.GetRepeated().ProtoReflect(), "max_length") {
-					opts = append(opts, fmt.Sprintf("max_length=%d", r.GetRepeated().GetMaxLength()))
-				}
-				isUUID = r.GetRepeated().GetItems().GetString_().GetUuid()
-			}
-		}
-
-		isOneOf := field.ContainingOneof() != nil && !field.HasOptionalKeyword()
-		if isOneOf {
-			oneofFields = append(oneofFields, "self."+string(field.Name()))
-		}
-		isOptional := field.HasOptionalKeyword() || isOneOf
-		fmtOpts := strings.Join(opts, ", ")
-		extras := getExtras(field, fmtOpts, defaultValue, defaultFactory)
-
-		if isOptional && defaultValue == "" && defaultFactory == "" {
-			f.P(t(d.indent+2), field.Name(), ": Optional[", fieldType.Reference(isUUID), "] = Field(", extras, ")")
-		} else if field.IsList() {
-			f.P(t(d.indent+2), field.Name(), ": ", fieldType.Reference(isUUID), " = Field(", extras, ")")
-		} else if field.IsMap() {
-			f.P(t(d.indent+2), field.Name(), ": ", fieldType.Reference(isUUID), " = Field(", extras, ")")
-			mapFields = append(mapFields, string(field.Name()))
-		} else {
-			f.P(t(d.indent+2), field.Name(), ": ", fieldType.Reference(isUUID), " = Field(", extras, ")")
-		}
-	})
+	f.P("class ", d.name, "Update(BaseModel):")
+	fields = d.variantFields(updateVariantFields(d.pydanticFields(message)))
+	d.generateVariantComment(f, updateVariantDescription(d.name), len(fields) == 0)
+	for _, pf := range fields {
+		d.generateFieldComments(f, pf.field)
+		f.P(t(d.indent+2), pf.declaration(d.target))
+	}
+	d.generateUnspecifiedValidators(f, fields)
+	d.generateResourceValidators(f, fields)
+	f.P()
+	f.P()
+	d.generateNestedVariant(f, d.name+"Update")
+}
 
-	if len(mapFields) > 0 {
-		f.P("")
-		f.P(t(d.indent+2), "@field_serializer(")
+// generateNestedVariant moves the variant of a nested message from the
+// module to the class enclosing the message, e.g. to Book.ChapterCreate.
+func (d descriptorGenerator) generateNestedVariant(f *codegen.File, name string) {
+	parent, ok := d.desc.Parent().(protoreflect.MessageDescriptor)
+	if !ok {
+		return
 	}
-	for _, field := range mapFields {
-		f.P(t(d.indent+4), `"`, field, `",`)
+	f.P(qualifiedTypeName(parent), ".", name, " = ", name)
+	f.P("del ", name)
+	f.P()
+	f.P()
+}
+
+// variantFields qualifies the references to nested types of the fields of a
+// variant, declared outside of the message.
+func (d descriptorGenerator) variantFields(fields []pydanticField) []pydanticField {
+	for i := range fields {
+		fields[i].qualify()
 	}
-	if len(mapFields) > 0 {
-		f.P(t(d.indent+2), ")")
-		f.P(t(d.indent+2), "def json_dump(self, v: dict, info: SerializationInfo):")
-		f.P(t(d.indent+4), "if info.context == 'bigquery':")
-		f.P(t(d.indent+6), "return json.dumps(v)")
-		f.P(t(d.indent+4), "return v")
+	return fields
+}
+
+// generateVariantComment describes an input variant in the comment style of
+// the module, with a pass statement if the variant has no field.
+func (d descriptorGenerator) generateVariantComment(f *codegen.File, text string, empty bool) {
+	if !d.hashComments() {
+		generateDocstring(f, d.indent+2, text)
+		return
+	}
+	f.P(t(d.indent+2), "# ", text)
+	if empty {
+		f.P(t(d.indent+2), "pass")
 	}
+}
 
-	if len(oneofFields) > 0 {
-		f.P("")
-		f.P(t(d.indent+2), `@model_validator(mode="after")`)
+func createVariantDescription(name string) string {
+	return "Input model for creating " + name + ", without its OUTPUT_ONLY fields."
+}
+
+func updateVariantDescription(name string) string {
+	return "Input model for updating " + name + ", where every mutable field is optional."
+}
+
+// createVariantFields returns the fields of the Create input model, without
+// OUTPUT_ONLY fields.
+func createVariantFields(fields []pydanticField) []pydanticField {
+	variant := make([]pydanticField, 0, len(fields))
+	for _, pf := range fields {
+		if pf.hasBehavior(annotations.FieldBehavior_OUTPUT_ONLY) {
+			continue
+		}
+		variant = append(variant, pf)
 	}
-	if len(oneofFields) > 0 {
-		f.P(t(d.indent+2), "def validate_one_ofs(self) -> Self:")
-		f.P(t(d.indent+4), `assert sum(x is not None for x in [`+strings.Join(oneofFields, ", ")+`]) == 1, \`)
-		f.P(t(d.indent+6), `ValueError("OneOf condition not met")`)
-		f.P(t(d.indent+4), "return self")
+	return variant
+}
+
+// updateVariantFields returns the fields of the Update input model, where
+// every mutable field is optional.
+func updateVariantFields(fields []pydanticField) []pydanticField {
+	variant := make([]pydanticField, 0, len(fields))
+	for _, pf := range fields {
+		if pf.hasBehavior(annotations.FieldBehavior_OUTPUT_ONLY) || pf.hasBehavior(annotations.FieldBehavior_IMMUTABLE) {
+			continue
+		}
+		pf.isOptional = true
+		pf.defaultValue = "default=None"
+		pf.defaultFactory = ""
+		variant = append(variant, pf)
+	}
+	return variant
+}
+
+func (d descriptorGenerator) pydanticFields(message protoreflect.MessageDescriptor) []pydanticField {
+	fields := make([]pydanticField, 0, message.Fields().Len())
+	rangeFields(message, func(field protoreflect.FieldDescriptor) {
+		pf := newPydanticField(d.pkg, field, d.params)
+		if !d.hashComments() {
+			pf.description = commentGenerator{descriptor: field}.text()
+		}
+		fields = append(fields, pf)
+	})
+	return fields
+}
+
+func (d descriptorGenerator) generateFieldComments(f *codegen.File, field protoreflect.FieldDescriptor) {
+	if d.hashComments() {
+		commentGenerator{descriptor: field}.generateLeading(f, d.indent+2)
+	} else {
+		commentGenerator{descriptor: field}.generateBehaviors(f, d.indent+2)
 	}
 }
 
-func getExtras(field protoreflect.FieldDescriptor, fmtOpts, defaultValue, defaultFactory string) string {
-	if field.HasOptionalKeyword() && defaultValue == "" {
-		defaultValue = "default=None"
+// generateProfileSerializer applies the transforms of the serialization
+// profile selected by the serialization context.
+func (d descriptorGenerator) generateProfileSerializer(f *codegen.File, fields []pydanticField) {
+	names := make([]string, 0)
+	kinds := make([]string, 0)
+	for _, pf := range fields {
+		fieldKinds := d.profiles.fieldKinds(pf.field)
+		if len(fieldKinds) == 0 {
+			continue
+		}
+		quoted := make([]string, 0, len(fieldKinds))
+		for _, kind := range fieldKinds {
+			quoted = append(quoted, strconv.Quote(kind)+",")
+		}
+		names = append(names, string(pf.field.Name()))
+		kinds = append(kinds, strconv.Quote(string(pf.field.Name()))+": ("+strings.Join(quoted, " ")+"),")
 	}
-	if field.ContainingOneof() != nil && !field.HasOptionalKeyword() {
-		defaultValue = "default=None"
+	if len(names) == 0 {
+		return
 	}
-	if field.IsList() && defaultFactory == "" {
-		defaultFactory = "default_factory=list"
+	f.P("")
+	f.P(t(d.indent+2), "__serialization_kinds__ = {")
+	for _, kind := range kinds {
+		f.P(t(d.indent+4), kind)
 	}
-	if field.IsMap() && defaultFactory == "" {
-		defaultFactory = "default_factory=dict"
+	f.P(t(d.indent+2), "}")
+	f.P("")
+	f.P(t(d.indent+2), "@field_serializer(")
+	for _, name := range names {
+		f.P(t(d.indent+4), `"`, name, `",`)
 	}
-	var extras []string
-	if fmtOpts != "" {
-		extras = append(extras, fmtOpts)
+	f.P(t(d.indent+4), `mode="wrap",`)
+	f.P(t(d.indent+2), ")")
+	f.P(t(d.indent+2), "def serialize_with_profile(self, v, handler, info: SerializationInfo):")
+	f.P(t(d.indent+4), "return _serialize_with_profile(v, handler, info, self.__serialization_kinds__[info.field_name])")
+}
+
+// generateUnspecifiedValidators maps a dropped *_UNSPECIFIED value to None on
+// optional enum fields.
+func (d descriptorGenerator) generateUnspecifiedValidators(f *codegen.File, fields []pydanticField) {
+	if !boolParam(d.params, "drop_unspecified") {
+		return
 	}
-	if defaultValue != "" {
-		extras = append(extras, defaultValue)
+	for _, pf := range fields {
+		if !pf.isOptional || pf.field.Enum() == nil {
+			continue
+		}
+		zero := pf.field.Enum().Values().ByNumber(0)
+		if zero == nil || !isUnspecifiedValue(zero) {
+			continue
+		}
+		f.P("")
+		f.P(t(d.indent+2), `@field_validator("`, pf.field.Name(), `", mode="before")`)
+		f.P(t(d.indent+2), "@classmethod")
+		f.P(t(d.indent+2), "def ", pf.field.Name(), "_unspecified_to_none(cls, v):")
+		// compare the type too, as False == 0 in Python
+		f.P(t(d.indent+4), "return None if v == ", strconv.Quote(string(zero.Name())), " or (type(v) is int and v == 0) else v")
 	}
-	if defaultFactory != "" {
-		extras = append(extras, defaultFactory)
+}
+
+// generateResourceValidators checks that resource name and reference fields
+// match the patterns of their resource.
+func (d descriptorGenerator) generateResourceValidators(f *codegen.File, fields []pydanticField) {
+	for _, pf := range fields {
+		resource := d.resources.fieldResource(pf.field)
+		if resource == nil {
+			continue
+		}
+		f.P("")
+		f.P(t(d.indent+2), `@field_validator("`, pf.field.Name(), `")`)
+		f.P(t(d.indent+2), "@classmethod")
+		f.P(t(d.indent+2), "def validate_", pf.field.Name(), "_resource_name(cls, v):")
+		if pf.field.IsList() {
+			f.P(t(d.indent+4), "for name in v or []:")
+		} else {
+			f.P(t(d.indent+4), "for name in [v] if v else []:")
+		}
+		f.P(t(d.indent+6), "if not ", resource.className, ".matches(name):")
+		f.P(t(d.indent+8), `raise ValueError(f"{name!r} is not a valid `, resource.resource.GetType(), ` name")`)
+		f.P(t(d.indent+4), "return v")
+	}
+}
+
+func (d descriptorGenerator) generateOneOfValidator(f *codegen.File, fields []pydanticField) {
+	oneofFields := make([]string, 0)
+	for _, pf := range fields {
+		if isOneOfField(pf.field) {
+			oneofFields = append(oneofFields, "self."+string(pf.field.Name()))
+		}
 	}
-	return strings.Join(extras, ", ")
+	if len(oneofFields) == 0 {
+		return
+	}
+	f.P("")
+	f.P(t(d.indent+2), `@model_validator(mode="after")`)
+	f.P(t(d.indent+2), "def validate_one_ofs(self) -> Self:")
+	f.P(t(d.indent+4), `assert sum(x is not None for x in [`+strings.Join(oneofFields, ", ")+`]) == 1, \`)
+	f.P(t(d.indent+6), `ValueError("OneOf condition not met")`)
+	f.P(t(d.indent+4), "return self")
+}
+
+// isUnspecifiedValue reports whether value is the conventional
+// *_UNSPECIFIED zero value of its enum.
+func isUnspecifiedValue(value protoreflect.EnumValueDescriptor) bool {
+	return value.Number() == 0 && strings.HasSuffix(string(value.Name()), "UNSPECIFIED")
 }
 
-func hasPresence(msg protoreflect.Message, field string) bool {
-	nameField := msg.Descriptor().Fields().ByName(protoreflect.Name(field))
-	return msg.Has(nameField)
+// primaryEnumValue returns the first declared value sharing value's number,
+// skipping a dropped *_UNSPECIFIED value.
+func primaryEnumValue(value protoreflect.EnumValueDescriptor, dropUnspecified bool) protoreflect.EnumValueDescriptor {
+	values := value.Parent().(protoreflect.EnumDescriptor).Values()
+	for i := 0; i < values.Len(); i++ {
+		v := values.Get(i)
+		if v.Number() != value.Number() || (dropUnspecified && isUnspecifiedValue(v)) {
+			continue
+		}
+		return v
+	}
+	return value
 }
@@ -0,0 +1,116 @@
+package plugin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cortea-ai/protoc-gen-pydantic/internal/codegen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// descriptorChunk is the number of descriptor bytes per line of a literal.
+const descriptorChunk = 64
+
+// fileDependencies returns files with their transitive imports, imports
+// first, as a descriptor pool needs them.
+func fileDependencies(files []protoreflect.FileDescriptor) []protoreflect.FileDescriptor {
+	seen := make(map[string]struct{})
+	ordered := make([]protoreflect.FileDescriptor, 0)
+	var visit func(file protoreflect.FileDescriptor)
+	visit = func(file protoreflect.FileDescriptor) {
+		if _, ok := seen[file.Path()]; ok {
+			return
+		}
+		seen[file.Path()] = struct{}{}
+		for i := 0; i < file.Imports().Len(); i++ {
+			visit(file.Imports().Get(i).FileDescriptor)
+		}
+		ordered = append(ordered, file)
+	}
+	for _, file := range files {
+		visit(file)
+	}
+	return ordered
+}
+
+// serializedFile returns the FileDescriptorProto of a file without source
+// code info, as protoc embeds it in _pb2 modules.
+func serializedFile(file protoreflect.FileDescriptor) ([]byte, error) {
+	fdp := protodesc.ToFileDescriptorProto(file)
+	fdp.SourceCodeInfo = nil
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(fdp)
+	if err != nil {
+		return nil, fmt.Errorf("serialize %s: %w", file.Path(), err)
+	}
+	return b, nil
+}
+
+// generateFileDescriptors emits the serialized files of the package and
+// their imports, with helpers loading them into a protobuf descriptor pool.
+func (p packageGenerator) generateFileDescriptors(f *codegen.File) error {
+	if !boolParam(p.params, "descriptors") {
+		return nil
+	}
+	f.P("FILE_DESCRIPTORS: ", p.target.generic("dict"), "[str, bytes] = {")
+	for _, file := range fileDependencies(p.moduleFiles()) {
+		b, err := serializedFile(file)
+		if err != nil {
+			return err
+		}
+		f.P(t(2), strconv.Quote(file.Path()), ": (")
+		for len(b) > 0 {
+			n := min(descriptorChunk, len(b))
+			f.P(t(4), pythonBytes(b[:n]))
+			b = b[n:]
+		}
+		f.P(t(2), "),")
+	}
+	f.P("}")
+	f.P()
+	f.P()
+	f.P("def add_file_descriptors(pool=None):")
+	f.P(t(2), `"""Adds FILE_DESCRIPTORS missing from a descriptor pool, the default pool if None."""`)
+	f.P(t(2), "from google.protobuf import descriptor_pool")
+	f.P()
+	f.P(t(2), "pool = pool if pool is not None else descriptor_pool.Default()")
+	f.P(t(2), "for name, data in FILE_DESCRIPTORS.items():")
+	f.P(t(4), "try:")
+	f.P(t(6), "pool.FindFileByName(name)")
+	f.P(t(4), "except KeyError:")
+	f.P(t(6), "pool.AddSerializedFile(data)")
+	f.P(t(2), "return pool")
+	f.P()
+	f.P()
+	f.P("def proto_descriptor(cls, pool=None):")
+	f.P(t(2), `"""Returns the protobuf Descriptor or EnumDescriptor of a generated class."""`)
+	f.P(t(2), "pool = add_file_descriptors(pool)")
+	f.P(t(2), "if issubclass(cls, ", p.target.enumClass(), "):")
+	f.P(t(4), "return pool.FindEnumTypeByName(cls.__proto_full_name__)")
+	f.P(t(2), "return pool.FindMessageTypeByName(cls.__proto_full_name__)")
+	f.P()
+	f.P()
+	return nil
+}
+
+// generateDescriptorAccessors records the proto identity of a class and the
+// numbers of its fields.
+func (d descriptorGenerator) generateDescriptorAccessors(f *codegen.File) {
+	if !boolParam(d.params, "descriptors") {
+		return
+	}
+	f.P("")
+	f.P(t(d.indent+2), "__proto_full_name__ = ", strconv.Quote(string(d.desc.FullName())))
+	f.P(t(d.indent+2), "__proto_file__ = ", strconv.Quote(d.desc.ParentFile().Path()))
+	message, ok := d.desc.(protoreflect.MessageDescriptor)
+	if !ok {
+		return
+	}
+	numbers := make([]string, 0, message.Fields().Len())
+	rangeFields(message, func(field protoreflect.FieldDescriptor) {
+		numbers = append(numbers, strconv.Quote(string(field.Name()))+": "+strconv.Itoa(int(field.Number())))
+	})
+	f.P(t(d.indent+2), "__proto_field_numbers__ = {", strings.Join(numbers, ", "), "}")
+}
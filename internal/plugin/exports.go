@@ -0,0 +1,167 @@
+package plugin
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cortea-ai/protoc-gen-pydantic/internal/codegen"
+	"github.com/cortea-ai/protoc-gen-pydantic/internal/protowalk"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// helperExports returns the public names of the helpers shared by the models
+// of a package.
+func (p packageGenerator) helperExports(resources resourceNames) []string {
+	names := []string{"ProtoEnum", "SERIALIZATION_PROFILES"}
+	if len(resources) > 0 {
+		names = append(names, "ResourceName")
+		for _, resource := range resources {
+			names = append(names, resource.className)
+		}
+	}
+	if boolParam(p.params, "strict_schema") {
+		names = append(names, "strict_json_schema")
+	}
+	if boolParam(p.params, "descriptors") {
+		names = append(names, "FILE_DESCRIPTORS", "add_file_descriptors", "proto_descriptor")
+	}
+	return names
+}
+
+// runtimeImports returns the helpers the models of a module with the file
+// layout import from the runtime module, including the private functions
+// their methods call.
+func (p packageGenerator) runtimeImports(resources resourceNames) []string {
+	names := []string{"ProtoEnum", "_serialize_with_profile"}
+	for _, resource := range resources {
+		names = append(names, resource.className)
+	}
+	if _, ok := p.params["pb2_module"]; ok {
+		names = append(names, "_from_proto", "_to_proto")
+	}
+	if boolParam(p.params, "wire_format") {
+		names = append(names, "_ProtoField", "_decode_message", "_encode_message")
+	}
+	sort.Strings(names)
+	return names
+}
+
+// typeExports returns the top-level models and enums declared by the
+// module, with their input variants.
+func (p packageGenerator) typeExports() []string {
+	names := make([]string, 0)
+	protowalk.WalkFiles(p.moduleFiles(), func(desc protoreflect.Descriptor) bool {
+		if desc.Parent() != desc.ParentFile() || desc.ParentFile().Package() != p.pkg {
+			return true
+		}
+		if _, ok := p.importedModule(desc); ok || !p.selected.has(desc) {
+			return true
+		}
+		switch desc := desc.(type) {
+		case protoreflect.EnumDescriptor:
+			names = append(names, string(desc.Name()))
+		case protoreflect.MessageDescriptor:
+			names = append(names, string(desc.Name()))
+			if boolParam(p.params, "input_variants") && hasFieldBehaviors(desc) {
+				names = append(names, string(desc.Name())+"Create", string(desc.Name())+"Update")
+			}
+		}
+		return true
+	})
+	return names
+}
+
+// generateExports emits the __all__ list of the module.
+func (p packageGenerator) generateExports(f *codegen.File, names []string) {
+	sort.Strings(names)
+	f.P("__all__ = [")
+	for _, name := range names {
+		f.P(t(2), strconv.Quote(name), ",")
+	}
+	f.P("]")
+	f.P()
+	f.P()
+}
+
+// packageInits returns the __init__.py files of the directories above the
+// generated packages, which make them regular packages unless
+// namespace_packages is set, and with root_init the __init__.py of the
+// module_prefix package importing every generated package.
+func (m moduleMap) packageInits(packages []protoreflect.FullName, params map[string]string) ([]*pluginpb.CodeGeneratorResponse_File, error) {
+	rootInit := boolParam(params, "root_init")
+	if rootInit && m.prefix == "" {
+		return nil, fmt.Errorf("root_init requires module_prefix")
+	}
+	generated := make(map[string]struct{}, len(packages))
+	for _, pkg := range packages {
+		generated[m.packageModule(pkg)] = struct{}{}
+	}
+	parents := make(map[string]struct{})
+	if !boolParam(params, "namespace_packages") {
+		for _, pkg := range packages {
+			elems := m.packageDir(pkg)
+			for i := 1; i < len(elems); i++ {
+				parent := strings.Join(elems[:i], ".")
+				if _, ok := generated[parent]; !ok {
+					parents[parent] = struct{}{}
+				}
+			}
+		}
+	}
+	if rootInit {
+		parents[m.prefix] = struct{}{}
+	}
+	dirs := make([]string, 0, len(parents))
+	for parent := range parents {
+		dirs = append(dirs, parent)
+	}
+	sort.Strings(dirs)
+
+	files := make([]*pluginpb.CodeGeneratorResponse_File, 0, len(dirs))
+	for _, dir := range dirs {
+		var content string
+		if rootInit && dir == m.prefix {
+			content = m.rootInit(packages)
+		}
+		files = append(files, &pluginpb.CodeGeneratorResponse_File{
+			Name:    proto.String(path.Join(append(strings.Split(dir, "."), "__init__.py")...)),
+			Content: proto.String(content),
+		})
+	}
+	return files, nil
+}
+
+// rootInit returns the __init__.py of the module_prefix package, importing
+// every generated package under its name with underscores.
+func (m moduleMap) rootInit(packages []protoreflect.FullName) string {
+	aliases := make([]string, 0, len(packages))
+	imports := make([]string, 0, len(packages))
+	for _, pkg := range packages {
+		module := strings.TrimPrefix(m.packageModule(pkg), m.prefix+".")
+		alias := strings.ReplaceAll(module, ".", "_")
+		if i := strings.LastIndex(module, "."); i >= 0 {
+			imports = append(imports, "from ."+module[:i]+" import "+module[i+1:]+" as "+alias)
+		} else {
+			imports = append(imports, "from . import "+module+" as "+alias)
+		}
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(imports)
+	sort.Strings(aliases)
+	var f codegen.File
+	for _, line := range imports {
+		f.P(line)
+	}
+	f.P()
+	f.P("__all__ = [")
+	for _, alias := range aliases {
+		f.P(t(2), strconv.Quote(alias), ",")
+	}
+	f.P("]")
+	return string(f.Content())
+}
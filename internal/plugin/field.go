@@ -0,0 +1,305 @@
+package plugin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cortea-ai/protoc-gen-pydantic/validate"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// pydanticField describes the declaration of a proto field on a model.
+type pydanticField struct {
+	field          protoreflect.FieldDescriptor
+	fieldType      Type
+	isUUID         bool
+	isOptional     bool
+	opts           []string
+	defaultValue   string
+	defaultFactory string
+	defaultEnum    protoreflect.EnumValueDescriptor
+	extras         []string
+	schemaExtra    []string
+	description    string
+}
+
+func newPydanticField(pkg protoreflect.FullName, field protoreflect.FieldDescriptor, params map[string]string) pydanticField {
+	pf := pydanticField{
+		field:      field,
+		fieldType:  typeFromField(pkg, field),
+		isOptional: hasExplicitPresence(field) || isOneOfField(field),
+	}
+	pf.applyProtoDefault()
+	pf.applyRules()
+	pf.applyDefaults()
+	// after applyDefaults, which would make a dropped zero enum value
+	// defaulting to None non optional
+	if boolParam(params, "zero_defaults") {
+		pf.applyZeroDefault(boolParam(params, "drop_unspecified"))
+	}
+	pf.applyBehaviors()
+	return pf
+}
+
+func isOneOfField(field protoreflect.FieldDescriptor) bool {
+	oneof := field.ContainingOneof()
+	return oneof != nil && !oneof.IsSynthetic()
+}
+
+// hasExplicitPresence reports whether a singular field tracks presence, as
+// resolved from the syntax or edition features of its file. Singular proto3
+// message fields keep being generated as non optional unless they use the
+// optional keyword, and so are message fields of editions files, which
+// always track presence.
+func hasExplicitPresence(field protoreflect.FieldDescriptor) bool {
+	if field.Cardinality() != protoreflect.Optional || isOneOfField(field) {
+		return false
+	}
+	switch field.Syntax() {
+	case protoreflect.Proto3:
+		return field.HasOptionalKeyword()
+	case protoreflect.Editions:
+		if field.Message() != nil {
+			return false
+		}
+	}
+	return field.HasPresence()
+}
+
+// applyProtoDefault uses the explicit [default = ...] of a proto2 field.
+func (pf *pydanticField) applyProtoDefault() {
+	if !pf.field.HasDefault() {
+		return
+	}
+	switch pf.field.Kind() {
+	case protoreflect.EnumKind:
+		pf.defaultEnum = pf.field.DefaultEnumValue()
+		pf.defaultValue = pf.enumDefault()
+	case protoreflect.BytesKind:
+		// bytes are generated as str
+		pf.defaultValue = "default=" + pythonSurrogateString(pf.field.Default().Bytes())
+	default:
+		pf.defaultValue = "default=" + pythonScalar(pf.field, pf.field.Default())
+	}
+}
+
+// applyZeroDefault gives an implicit presence field its proto zero value, so
+// a missing field parses the way it does in proto.
+func (pf *pydanticField) applyZeroDefault(dropUnspecified bool) {
+	if pf.isOptional || pf.defaultValue != "" || pf.defaultFactory != "" || pf.isUUID ||
+		pf.field.Cardinality() != protoreflect.Optional {
+		return
+	}
+	switch pf.field.Kind() {
+	case protoreflect.StringKind, protoreflect.BytesKind:
+		pf.defaultValue = `default=""`
+	case protoreflect.BoolKind:
+		pf.defaultValue = "default=False"
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		pf.defaultValue = "default=0.0"
+	case protoreflect.EnumKind:
+		zero := pf.field.Enum().Values().Get(0)
+		if dropUnspecified && isUnspecifiedValue(zero) {
+			// the zero value has no member, None stands in for it
+			pf.isOptional = true
+			pf.defaultValue = "default=None"
+			return
+		}
+		pf.defaultEnum = zero
+		pf.defaultValue = pf.enumDefault()
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		switch wkt, _ := WellKnownType(pf.field.Message()); wkt {
+		case WellKnownTimestamp:
+			pf.defaultValue = "default=datetime.datetime(1970, 1, 1, tzinfo=datetime.timezone.utc)"
+		case WellKnownDuration:
+			pf.defaultValue = "default=datetime.timedelta()"
+		case "":
+			pf.defaultFactory = "default_factory=" + pf.fieldType.Reference(false)
+		}
+	default:
+		pf.defaultValue = "default=0"
+	}
+}
+
+func (pf pydanticField) enumDefault() string {
+	return "default=" + pf.fieldType.Reference(false) + "." + string(pf.defaultEnum.Name())
+}
+
+// qualify references nested types by their qualified names, for fields
+// declared outside of the enclosing message.
+func (pf *pydanticField) qualify() {
+	hasEnumDefault := pf.defaultEnum != nil && pf.defaultValue == pf.enumDefault()
+	hasFactory := pf.field.Message() != nil && pf.defaultFactory == "default_factory="+pf.fieldType.Reference(false)
+	pf.fieldType = pf.fieldType.Qualified()
+	if hasEnumDefault {
+		pf.defaultValue = pf.enumDefault()
+	}
+	if hasFactory {
+		pf.defaultFactory = "default_factory=" + pf.fieldType.Reference(false)
+	}
+}
+
+func (pf *pydanticField) applyRules() {
+	rules := proto.GetExtension(pf.field.Options(), validate.E_Rules)
+	r, ok := rules.(*validate.FieldRules)
+	if !ok {
+		return
+	}
+	if r.GetFloat() != nil {
+		if hasPresence(r.GetFloat().ProtoReflect(), "default") {
+			pf.defaultValue = fmt.Sprintf("default=%.1f", r.GetFloat().GetDefault())
+		}
+		if hasPresence(r.GetFloat().ProtoReflect(), "lt") {
+			pf.opts = append(pf.opts, fmt.Sprintf("lt=%.1f", r.GetFloat().GetLt()))
+		}
+		if hasPresence(r.GetFloat().ProtoReflect(), "gt") {
+			pf.opts = append(pf.opts, fmt.Sprintf("gt=%.1f", r.GetFloat().GetGt()))
+		}
+		if hasPresence(r.GetFloat().ProtoReflect(), "gte") {
+			pf.opts = append(pf.opts, fmt.Sprintf("gte=%.1f", r.GetFloat().GetGte()))
+		}
+		if hasPresence(r.GetFloat().ProtoReflect(), "lte") {
+			pf.opts = append(pf.opts, fmt.Sprintf("lte=%.1f", r.GetFloat().GetLte()))
+		}
+	}
+	if r.GetInt32() != nil {
+		if hasPresence(r.GetInt32().ProtoReflect(), "default") {
+			pf.defaultValue = fmt.Sprintf("default=%d", r.GetInt32().GetDefault())
+		}
+		if hasPresence(r.GetInt32().ProtoReflect(), "lt") {
+			pf.opts = append(pf.opts, fmt.Sprintf("lt=%d", r.GetInt32().GetLt()))
+		}
+		if hasPresence(r.GetInt32().ProtoReflect(), "gt") {
+			pf.opts = append(pf.opts, fmt.Sprintf("gt=%d", r.GetInt32().GetGt()))
+		}
+		if hasPresence(r.GetInt32().ProtoReflect(), "gte") {
+			pf.opts = append(pf.opts, fmt.Sprintf("gte=%d", r.GetInt32().GetGte()))
+		}
+		if hasPresence(r.GetInt32().ProtoReflect(), "lte") {
+			pf.opts = append(pf.opts, fmt.Sprintf("lte=%d", r.GetInt32().GetLte()))
+		}
+	}
+	if r.GetString_() != nil {
+		if hasPresence(r.GetString_().ProtoReflect(), "default") {
+			pf.defaultValue = fmt.Sprintf(`default="%s"`, r.GetString_().GetDefault())
+		}
+		if hasPresence(r.GetString_().ProtoReflect(), "len") {
+			pf.opts = append(pf.opts, fmt.Sprintf("len=%d", r.GetString_().GetLen()))
+		}
+		if hasPresence(r.GetString_().ProtoReflect(), "min_length") {
+			pf.opts = append(pf.opts, fmt.Sprintf("min_length=%d", r.GetString_().GetMinLength()))
+		}
+		if hasPresence(r.GetString_().ProtoReflect(), "max_length") {
+			pf.opts = append(pf.opts, fmt.Sprintf("max_length=%d", r.GetString_().GetMaxLength()))
+		}
+		pf.isUUID = r.GetString_().GetUuid()
+	}
+	if r.GetMessage() != nil {
+		if hasPresence(r.GetMessage().ProtoReflect(), "default_factory") {
+			pf.defaultFactory = fmt.Sprintf(`default_factory="%s"`, r.GetMessage().GetDefaultFactory())
+		}
+		if hasPresence(r.GetMessage().ProtoReflect(), "default_empty") && r.GetMessage().GetDefaultEmpty() {
+			pf.defaultValue = fmt.Sprintf(`default_factory=%s`, pf.fieldType.Reference(pf.isUUID))
+		}
+	}
+	if r.GetRepeated() != nil {
+		if hasPresence(r.GetRepeated().ProtoReflect(), "len") {
+			pf.opts = append(pf.opts, fmt.Sprintf("len=%d", r.GetRepeated().GetLen()))
+		}
+		if hasPresence(r.GetRepeated().ProtoReflect(), "min_length") {
+			pf.opts = append(pf.opts, fmt.Sprintf("min_length=%d", r.GetRepeated().GetMinLength()))
+		}
+		if hasPresence(r.GetRepeated().ProtoReflect(), "max_length") {
+			pf.opts = append(pf.opts, fmt.Sprintf("max_length=%d", r.GetRepeated().GetMaxLength()))
+		}
+		pf.isUUID = r.GetRepeated().GetItems().GetString_().GetUuid()
+	}
+}
+
+func (pf *pydanticField) applyDefaults() {
+	// Optional[...] is only used when no default is configured by the rules.
+	pf.isOptional = pf.isOptional && pf.defaultValue == "" && pf.defaultFactory == ""
+	if hasExplicitPresence(pf.field) && pf.defaultValue == "" {
+		pf.defaultValue = "default=None"
+	}
+	if isOneOfField(pf.field) {
+		pf.defaultValue = "default=None"
+	}
+	if pf.field.IsList() && pf.defaultFactory == "" {
+		pf.defaultFactory = "default_factory=list"
+	}
+	if pf.field.IsMap() && pf.defaultFactory == "" {
+		pf.defaultFactory = "default_factory=dict"
+	}
+}
+
+// applyBehaviors maps google.api.field_behavior annotations onto the field.
+func (pf *pydanticField) applyBehaviors() {
+	for _, behavior := range getFieldBehaviors(pf.field) {
+		switch behavior {
+		case annotations.FieldBehavior_REQUIRED:
+			if !isOneOfField(pf.field) {
+				pf.isOptional = false
+				pf.defaultValue = ""
+				pf.defaultFactory = ""
+			}
+		case annotations.FieldBehavior_OUTPUT_ONLY:
+			// set by the server, so input may omit it; the model still
+			// accepts it, as servers fill it, and only the input variants
+			// exclude it
+			if pf.defaultValue == "" && pf.defaultFactory == "" {
+				pf.isOptional = true
+				pf.defaultValue = "default=None"
+			}
+			pf.schemaExtra = append(pf.schemaExtra, `"readOnly": True`)
+		case annotations.FieldBehavior_INPUT_ONLY:
+			pf.extras = append(pf.extras, "exclude=True")
+			pf.schemaExtra = append(pf.schemaExtra, `"writeOnly": True`)
+		case annotations.FieldBehavior_IMMUTABLE:
+			pf.extras = append(pf.extras, "frozen=True")
+		}
+	}
+}
+
+func (pf pydanticField) hasBehavior(behavior annotations.FieldBehavior) bool {
+	return hasFieldBehavior(pf.field, behavior)
+}
+
+// annotation returns the Python type annotation of the field.
+func (pf pydanticField) annotation(target pythonTarget) string {
+	if pf.isOptional {
+		return target.optional(target.reference(pf.fieldType, pf.isUUID))
+	}
+	return target.reference(pf.fieldType, pf.isUUID)
+}
+
+// fieldArgs returns the arguments passed to Field().
+func (pf pydanticField) fieldArgs() string {
+	args := append([]string(nil), pf.opts...)
+	if pf.defaultValue != "" {
+		args = append(args, pf.defaultValue)
+	}
+	if pf.defaultFactory != "" {
+		args = append(args, pf.defaultFactory)
+	}
+	args = append(args, pf.extras...)
+	if len(pf.schemaExtra) > 0 {
+		args = append(args, "json_schema_extra={"+strings.Join(pf.schemaExtra, ", ")+"}")
+	}
+	if pf.description != "" {
+		args = append(args, "description="+strconv.Quote(pf.description))
+	}
+	return strings.Join(args, ", ")
+}
+
+func (pf pydanticField) declaration(target pythonTarget) string {
+	return string(pf.field.Name()) + ": " + pf.annotation(target) + " = Field(" + pf.fieldArgs() + ")"
+}
+
+func hasPresence(msg protoreflect.Message, field string) bool {
+	nameField := msg.Descriptor().Fields().ByName(protoreflect.Name(field))
+	return msg.Has(nameField)
+}
@@ -2,7 +2,10 @@ package plugin
 
 import (
 	"fmt"
+	"os"
 	"path"
+	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/cortea-ai/protoc-gen-pydantic/internal/codegen"
@@ -35,7 +38,38 @@ func Generate(request *pluginpb.CodeGeneratorRequest) (*pluginpb.CodeGeneratorRe
 
 	params := parseParameters(request.GetParameter())
 
-	packageSuffix := params["package_suffix"]
+	if includePath, ok := params["include_path"]; ok {
+		for pkg := range packaged {
+			if !strings.HasPrefix(string(pkg), includePath) {
+				delete(packaged, pkg)
+			}
+		}
+	}
+	selected, err := parseSelection(registry, packaged, params)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := params["roots"]; ok {
+		files := make([]protoreflect.FileDescriptor, 0)
+		for _, pkg := range sortedPackages(packaged) {
+			files = append(files, packaged[pkg]...)
+		}
+		pruned, total := selected.pruned(files)
+		fmt.Fprintf(os.Stderr, "%s: roots: pruned %d of %d messages and enums\n", filepath.Base(os.Args[0]), pruned, total)
+	}
+	for pkg, files := range packaged {
+		if !selected.hasAny(files) {
+			delete(packaged, pkg)
+		}
+	}
+	// generate packages and their files in a fixed order, whatever the order
+	// of the request, so that the output is reproducible
+	packages := sortedPackages(packaged)
+	for _, files := range packaged {
+		sort.Slice(files, func(i, j int) bool {
+			return files[i].Path() < files[j].Path()
+		})
+	}
 
 	var filename string
 	var ok bool
@@ -43,35 +77,153 @@ func Generate(request *pluginpb.CodeGeneratorRequest) (*pluginpb.CodeGeneratorRe
 		filename = "pb_models"
 	}
 
+	types := extensionTypes(registry)
+	profiles, err := parseProfiles(params)
+	if err != nil {
+		return nil, err
+	}
+	layout, err := parseLayout(params)
+	if err != nil {
+		return nil, err
+	}
+	target, err := parsePythonTarget(params)
+	if err != nil {
+		return nil, err
+	}
+	modules, err := newModuleMap(packaged, params, filename, layout)
+	if err != nil {
+		return nil, err
+	}
+
 	var res pluginpb.CodeGeneratorResponse
-	for pkg, files := range packaged {
-		if includePath, ok := params["include_path"]; ok {
-			if !strings.HasPrefix(string(pkg), includePath) {
-				continue
+	for _, pkg := range packages {
+		files := packaged[pkg]
+		generator := packageGenerator{pkg: pkg, files: files, params: params, types: types, profiles: profiles, target: target, modules: modules, selected: selected}
+		init := "from ." + filename + " import *\n"
+		if layout == layoutFile {
+			var runtime codegen.File
+			generator.runtime = runtimeModule(filename)
+			generator.module = modules.packageModule(pkg) + "." + generator.runtime
+			if err := generator.GenerateRuntime(&runtime); err != nil {
+				return nil, err
+			}
+			res.File = append(res.File, moduleFiles(modules.packageDir(pkg), generator.runtime, &runtime, generator.GenerateRuntimeStub, params)...)
+			init = "from ." + generator.runtime + " import *\n"
+			for _, file := range files {
+				if !selected.hasAny([]protoreflect.FileDescriptor{file}) {
+					continue
+				}
+				var module codegen.File
+				generator.file = file
+				generator.module = modules.generated[file.Path()]
+				if err := generator.checkReferences(); err != nil {
+					return nil, err
+				}
+				if err := generator.Generate(&module); err != nil {
+					return nil, err
+				}
+				name := generator.module[strings.LastIndex(generator.module, ".")+1:]
+				res.File = append(res.File, moduleFiles(modules.packageDir(pkg), name, &module, generator.GenerateStub, params)...)
+				init += "from ." + name + " import *\n"
 			}
+		} else {
+			var index codegen.File
+			generator.module = modules.packageModule(pkg) + "." + filename
+			if err := generator.checkReferences(); err != nil {
+				return nil, err
+			}
+			if err := generator.Generate(&index); err != nil {
+				return nil, err
+			}
+			res.File = append(res.File, moduleFiles(modules.packageDir(pkg), filename, &index, generator.GenerateStub, params)...)
 		}
-		var index codegen.File
-		indexPathElems := append(strings.Split(string(pkg)+packageSuffix, "."), filename+".py")
-		(packageGenerator{pkg: pkg, files: files, params: params}).Generate(&index)
+		indexPathElems := append(modules.packageDir(pkg), "__init__.py")
 		res.File = append(res.File, &pluginpb.CodeGeneratorResponse_File{
 			Name:    proto.String(path.Join(indexPathElems...)),
-			Content: proto.String(string(index.Content())),
+			Content: proto.String(init),
 		})
-		indexPathElems = append(strings.Split(string(pkg)+packageSuffix, "."), "__init__.py")
-		res.File = append(res.File, &pluginpb.CodeGeneratorResponse_File{
-			Name:    proto.String(path.Join(indexPathElems...)),
-			Content: proto.String("from ." + filename + " import *\n"),
-		})
-		indexPathElems = append(strings.Split(string(pkg)+packageSuffix, "."), "py.typed")
+		indexPathElems = append(modules.packageDir(pkg), "py.typed")
 		res.File = append(res.File, &pluginpb.CodeGeneratorResponse_File{
 			Name:    proto.String(path.Join(indexPathElems...)),
 			Content: proto.String(""),
 		})
+		if boolParam(params, "json_schema") {
+			schema := jsonSchemaGenerator{pkg: pkg, files: files, params: params, resources: packageResources(pkg, files), selected: selected}
+			content, err := schema.Generate()
+			if err != nil {
+				return nil, err
+			}
+			indexPathElems = append(modules.packageDir(pkg), filename+schema.extension())
+			res.File = append(res.File, &pluginpb.CodeGeneratorResponse_File{
+				Name:    proto.String(path.Join(indexPathElems...)),
+				Content: proto.String(string(content)),
+			})
+		}
+		tables, err := packageBigQueryTables(pkg, files, profiles, selected)
+		if err != nil {
+			return nil, err
+		}
+		for _, table := range tables {
+			schema, err := table.schema()
+			if err != nil {
+				return nil, err
+			}
+			indexPathElems = append(modules.packageDir(pkg), descriptorTypeName(table.message)+".schema.json")
+			res.File = append(res.File, &pluginpb.CodeGeneratorResponse_File{
+				Name:    proto.String(path.Join(indexPathElems...)),
+				Content: proto.String(string(schema)),
+			})
+		}
+	}
+	inits, err := modules.packageInits(packages, params)
+	if err != nil {
+		return nil, err
 	}
-	res.SupportedFeatures = proto.Uint64(uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL))
+	res.File = append(res.File, inits...)
+	res.SupportedFeatures = proto.Uint64(uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL |
+		pluginpb.CodeGeneratorResponse_FEATURE_SUPPORTS_EDITIONS))
+	res.MinimumEdition = proto.Int32(int32(descriptorpb.Edition_EDITION_PROTO2))
+	res.MaximumEdition = proto.Int32(int32(descriptorpb.Edition_EDITION_2023))
 	return &res, nil
 }
 
+// moduleFiles returns the files of the module name in the package directory
+// dir: its source and, with the stubs parameter, the stub generateStub writes.
+func moduleFiles(dir []string, name string, module *codegen.File, generateStub func(*codegen.File), params map[string]string) []*pluginpb.CodeGeneratorResponse_File {
+	files := []*pluginpb.CodeGeneratorResponse_File{{
+		Name:    proto.String(path.Join(append(dir, name+".py")...)),
+		Content: proto.String(string(module.Content())),
+	}}
+	if boolParam(params, "stubs") {
+		var stub codegen.File
+		generateStub(&stub)
+		files = append(files, &pluginpb.CodeGeneratorResponse_File{
+			Name:    proto.String(path.Join(append(dir, name+".pyi")...)),
+			Content: proto.String(string(stub.Content())),
+		})
+	}
+	return files
+}
+
+func sortedPackages(packaged map[protoreflect.FullName][]protoreflect.FileDescriptor) []protoreflect.FullName {
+	packages := make([]protoreflect.FullName, 0, len(packaged))
+	for pkg := range packaged {
+		packages = append(packages, pkg)
+	}
+	sort.Slice(packages, func(i, j int) bool {
+		return packages[i] < packages[j]
+	})
+	return packages
+}
+
+// listParameters are the parameters that may be repeated, read with
+// listParam.
+var listParameters = map[string]bool{
+	"include": true,
+	"exclude": true,
+	"roots":   true,
+}
+
 func parseParameters(parameter string) map[string]string {
 	params := make(map[string]string)
 	for _, param := range strings.Split(parameter, ",") {
@@ -81,9 +233,19 @@ func parseParameters(parameter string) map[string]string {
 		parts := strings.SplitN(param, "=", 2)
 		if len(parts) == 1 {
 			params[parts[0]] = ""
+		} else if v, ok := params[parts[0]]; ok && listParameters[parts[0]] {
+			// list parameters are repeated, one value each
+			params[parts[0]] = v + "," + parts[1]
 		} else {
 			params[parts[0]] = parts[1]
 		}
 	}
 	return params
 }
+
+// boolParam reports whether a flag parameter is set, either bare or with a
+// value other than "false".
+func boolParam(params map[string]string, name string) bool {
+	v, ok := params[name]
+	return ok && v != "false"
+}
@@ -0,0 +1,1269 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+const deterministicRequest = `
+file_to_generate: "acme/shop/v1/order.proto"
+file_to_generate: "acme/billing/v1/invoice.proto"
+file_to_generate: "acme/shop/v1/user.proto"
+file_to_generate: "acme/catalog/v1/item.proto"
+proto_file {
+  name: "acme/shop/v1/user.proto"
+  package: "acme.shop.v1"
+  syntax: "proto3"
+  enum_type {
+    name: "Role"
+    value { name: "ROLE_UNSPECIFIED" number: 0 }
+    value { name: "ADMIN" number: 1 }
+  }
+  message_type {
+    name: "User"
+    field { name: "name" number: 1 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "name" }
+    field { name: "role" number: 2 label: LABEL_OPTIONAL type: TYPE_ENUM type_name: ".acme.shop.v1.Role" json_name: "role" }
+  }
+}
+proto_file {
+  name: "acme/catalog/v1/item.proto"
+  package: "acme.catalog.v1"
+  syntax: "proto3"
+  message_type {
+    name: "Item"
+    field { name: "sku" number: 1 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "sku" }
+    field { name: "tags" number: 2 label: LABEL_REPEATED type: TYPE_STRING json_name: "tags" }
+  }
+}
+proto_file {
+  name: "acme/shop/v1/order.proto"
+  package: "acme.shop.v1"
+  syntax: "proto3"
+  dependency: "acme/shop/v1/user.proto"
+  dependency: "acme/catalog/v1/item.proto"
+  message_type {
+    name: "Order"
+    field { name: "buyer" number: 1 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".acme.shop.v1.User" json_name: "buyer" }
+    field { name: "items" number: 2 label: LABEL_REPEATED type: TYPE_MESSAGE type_name: ".acme.catalog.v1.Item" json_name: "items" }
+  }
+}
+proto_file {
+  name: "acme/billing/v1/invoice.proto"
+  package: "acme.billing.v1"
+  syntax: "proto3"
+  dependency: "acme/shop/v1/order.proto"
+  message_type {
+    name: "Invoice"
+    field { name: "order" number: 1 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".acme.shop.v1.Order" json_name: "order" }
+  }
+}
+`
+
+func generateMarshaled(t *testing.T, request *pluginpb.CodeGeneratorRequest) []byte {
+	t.Helper()
+	res, err := Generate(request)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if res.GetError() != "" {
+		t.Fatalf("Generate: %s", res.GetError())
+	}
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(res)
+	if err != nil {
+		t.Fatalf("marshal response: %v", err)
+	}
+	return b
+}
+
+func TestGenerateDeterministic(t *testing.T) {
+	for _, parameter := range []string{
+		"",
+		"layout=file,module_prefix=gen,root_init,descriptors,json_schema,profile.a=maps_as_json,profile.b=enums_as_int",
+	} {
+		t.Run(parameter, func(t *testing.T) {
+			var request pluginpb.CodeGeneratorRequest
+			if err := prototext.Unmarshal([]byte(deterministicRequest), &request); err != nil {
+				t.Fatalf("unmarshal request: %v", err)
+			}
+			request.Parameter = proto.String(parameter)
+			want := generateMarshaled(t, &request)
+			for i := 0; i < 20; i++ {
+				if got := generateMarshaled(t, &request); !bytes.Equal(got, want) {
+					t.Fatalf("run %d: response differs from the first run", i+1)
+				}
+			}
+
+			// the order of the files to generate does not matter either
+			files := request.FileToGenerate
+			for i, j := 0, len(files)-1; i < j; i, j = i+1, j-1 {
+				files[i], files[j] = files[j], files[i]
+			}
+			if got := generateMarshaled(t, &request); !bytes.Equal(got, want) {
+				t.Fatal("response depends on the order of the files to generate")
+			}
+		})
+	}
+}
+
+const unspecifiedRequest = `
+file_to_generate: "acme/library/v1/book.proto"
+parameter: "zero_defaults,drop_unspecified"
+proto_file {
+  name: "google/protobuf/timestamp.proto"
+  package: "google.protobuf"
+  syntax: "proto3"
+  message_type {
+    name: "Timestamp"
+    field { name: "seconds" number: 1 label: LABEL_OPTIONAL type: TYPE_INT64 json_name: "seconds" }
+    field { name: "nanos" number: 2 label: LABEL_OPTIONAL type: TYPE_INT32 json_name: "nanos" }
+  }
+}
+proto_file {
+  name: "google/protobuf/duration.proto"
+  package: "google.protobuf"
+  syntax: "proto3"
+  message_type {
+    name: "Duration"
+    field { name: "seconds" number: 1 label: LABEL_OPTIONAL type: TYPE_INT64 json_name: "seconds" }
+    field { name: "nanos" number: 2 label: LABEL_OPTIONAL type: TYPE_INT32 json_name: "nanos" }
+  }
+}
+proto_file {
+  name: "acme/library/v1/book.proto"
+  package: "acme.library.v1"
+  syntax: "proto3"
+  dependency: "google/protobuf/timestamp.proto"
+  dependency: "google/protobuf/duration.proto"
+  enum_type {
+    name: "Kind"
+    value { name: "KIND_UNSPECIFIED" number: 0 }
+    value { name: "NOVEL" number: 1 }
+  }
+  message_type {
+    name: "Book"
+    field { name: "kind" number: 1 label: LABEL_OPTIONAL type: TYPE_ENUM type_name: ".acme.library.v1.Kind" json_name: "kind" }
+    field { name: "pages" number: 2 label: LABEL_OPTIONAL type: TYPE_INT32 json_name: "pages" }
+    field { name: "create_time" number: 3 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".google.protobuf.Timestamp" json_name: "createTime" }
+    field { name: "read_time" number: 4 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".google.protobuf.Duration" json_name: "readTime" }
+  }
+}
+`
+
+func generateFile(t *testing.T, request, name string) string {
+	t.Helper()
+	var req pluginpb.CodeGeneratorRequest
+	if err := prototext.Unmarshal([]byte(request), &req); err != nil {
+		t.Fatalf("unmarshal request: %v", err)
+	}
+	return generateRequestFile(t, &req, name)
+}
+
+func generateRequestFile(t *testing.T, req *pluginpb.CodeGeneratorRequest, name string) string {
+	t.Helper()
+	res, err := Generate(req)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	for _, file := range res.GetFile() {
+		if file.GetName() == name {
+			return file.GetContent()
+		}
+	}
+	t.Fatalf("Generate: no file %s", name)
+	return ""
+}
+
+func TestGenerateZeroDefaultsDropUnspecified(t *testing.T) {
+	content := generateFile(t, unspecifiedRequest, "acme/library/v1/pb_models.py")
+	for _, want := range []string{
+		// the dropped zero value is None, which the field must accept
+		"kind: Optional[Kind] = Field(default=None)",
+		`@field_validator("kind", mode="before")`,
+		`return None if v == "KIND_UNSPECIFIED" or (type(v) is int and v == 0) else v`,
+		"pages: int = Field(default=0)",
+		// omitted by protojson like any zero value
+		"create_time: datetime.datetime = Field(default=datetime.datetime(1970, 1, 1, tzinfo=datetime.timezone.utc))",
+		"read_time: datetime.timedelta = Field(default=datetime.timedelta())",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("generated module does not contain %q:\n%s", want, content)
+		}
+	}
+
+	// a message without any field, as protojson writes a zero one
+	out := runGenerated(t, generateRequest(t, unspecifiedRequest), nil, `
+from acme.library.v1.pb_models import Book
+
+book = Book()
+print(book.kind, book.pages, book.create_time.isoformat(), book.read_time.total_seconds())
+`)
+	if want := "None 0 1970-01-01T00:00:00+00:00 0.0\n"; out != want {
+		t.Errorf("an empty book prints %q, want %q", out, want)
+	}
+}
+
+func TestGenerateFileLayoutRuntime(t *testing.T) {
+	request := `parameter: "layout=file"` + deterministicRequest
+	runtime := generateFile(t, request, "acme/shop/v1/pb_models_runtime.py")
+	if !strings.Contains(runtime, "class ProtoEnum(") {
+		t.Errorf("runtime module does not define ProtoEnum:\n%s", runtime)
+	}
+	// the file modules share the helpers of the runtime module
+	for _, name := range []string{"acme/shop/v1/user_pb_models.py", "acme/shop/v1/order_pb_models.py"} {
+		content := generateFile(t, request, name)
+		if strings.Contains(content, "class ProtoEnum(") {
+			t.Errorf("%s defines its own ProtoEnum:\n%s", name, content)
+		}
+		if !strings.Contains(content, "from .pb_models_runtime import ") {
+			t.Errorf("%s does not import the runtime module:\n%s", name, content)
+		}
+	}
+	if init := generateFile(t, request, "acme/shop/v1/__init__.py"); !strings.HasPrefix(init, "from .pb_models_runtime import *\n") {
+		t.Errorf("__init__.py does not re-export the runtime module:\n%s", init)
+	}
+}
+
+func TestGeneratePythonVersionDefault(t *testing.T) {
+	module := "acme/library/v1/pb_models.py"
+	want := generateFile(t, unspecifiedRequest, module)
+	explicit := strings.Replace(unspecifiedRequest, `parameter: "`, `parameter: "python_version=3.11,`, 1)
+	if got := generateFile(t, explicit, module); got != want {
+		t.Errorf("python_version=3.11 differs from the default:\n%s", got)
+	}
+	noneUnion := strings.Replace(unspecifiedRequest, `parameter: "`, `parameter: "none_union,`, 1)
+	if got := generateFile(t, noneUnion, module); !strings.Contains(got, "kind: Kind | None = Field(default=None)") {
+		t.Errorf("none_union does not write X | None:\n%s", got)
+	}
+
+	var request pluginpb.CodeGeneratorRequest
+	if err := prototext.Unmarshal([]byte(unspecifiedRequest), &request); err != nil {
+		t.Fatalf("unmarshal request: %v", err)
+	}
+	request.Parameter = proto.String("none_union,python_version=3.9")
+	if _, err := Generate(&request); err == nil {
+		t.Error("Generate accepted none_union with python_version=3.9")
+	}
+}
+
+const enumRequest = `
+file_to_generate: "acme/tasks/v1/task.proto"
+parameter: "drop_unspecified"
+proto_file {
+  name: "acme/tasks/v1/task.proto"
+  package: "acme.tasks.v1"
+  syntax: "proto3"
+  enum_type {
+    name: "Status"
+    value { name: "STATUS_UNSPECIFIED" number: 0 }
+    value { name: "ACTIVE" number: 1 }
+    value { name: "RUNNING" number: 1 }
+    options { allow_alias: true }
+    reserved_range { start: 5 end: 7 }
+    reserved_name: "DELETED"
+  }
+  message_type {
+    name: "Task"
+    field { name: "status" number: 1 label: LABEL_OPTIONAL type: TYPE_ENUM type_name: ".acme.tasks.v1.Status" json_name: "status" }
+    field { name: "previous" number: 2 label: LABEL_OPTIONAL type: TYPE_ENUM type_name: ".acme.tasks.v1.Status" json_name: "previous" oneof_index: 0 proto3_optional: true }
+    oneof_decl { name: "_previous" }
+  }
+}
+`
+
+func TestGenerateEnumAliasesAndReserved(t *testing.T) {
+	content := generateFile(t, enumRequest, "acme/tasks/v1/pb_models.py")
+	for _, want := range []string{
+		// the dropped zero value leaves ACTIVE as the primary value of 1
+		"    ACTIVE = \"ACTIVE\"\n    RUNNING = ACTIVE\n",
+		`__reserved_names__ = frozenset({"DELETED"})`,
+		"__reserved_numbers__ = ((5, 7),)",
+		`raise ValueError(f"{value!r} is reserved in {cls.__name__}")`,
+		// False == 0 in Python, so only ints map to None
+		`return None if v == "STATUS_UNSPECIFIED" or (type(v) is int and v == 0) else v`,
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("generated module does not contain %q:\n%s", want, content)
+		}
+	}
+	if strings.Contains(content, "STATUS_UNSPECIFIED = ") {
+		t.Errorf("generated module declares the dropped zero value:\n%s", content)
+	}
+}
+
+const enumMetadataRequest = `
+file_to_generate: "acme/paint/v1/color.proto"
+proto_file {
+  name: "acme/paint/v1/options.proto"
+  package: "acme.paint.v1"
+  syntax: "proto3"
+  dependency: "google/protobuf/descriptor.proto"
+  extension { name: "hex" number: 50000 label: LABEL_OPTIONAL type: TYPE_STRING extendee: ".google.protobuf.EnumValueOptions" json_name: "hex" }
+}
+proto_file {
+  name: "acme/paint/v1/color.proto"
+  package: "acme.paint.v1"
+  syntax: "proto3"
+  dependency: "acme/paint/v1/options.proto"
+  enum_type {
+    name: "Color"
+    value { name: "COLOR_UNSPECIFIED" number: 0 }
+    value { name: "RED" number: 1 options { deprecated: true } }
+  }
+  source_code_info {
+    location { path: [5, 0, 2, 1] span: [4, 2, 10] leading_comments: " Bright red.\n" }
+  }
+}
+`
+
+func TestGenerateEnumMetadata(t *testing.T) {
+	var request pluginpb.CodeGeneratorRequest
+	if err := prototext.Unmarshal([]byte(enumMetadataRequest), &request); err != nil {
+		t.Fatalf("unmarshal request: %v", err)
+	}
+	descriptor := protodesc.ToFileDescriptorProto(descriptorpb.File_google_protobuf_descriptor_proto)
+	request.ProtoFile = append([]*descriptorpb.FileDescriptorProto{descriptor}, request.ProtoFile...)
+	// set (acme.paint.v1.hex) = "#ff0000" on RED, which prototext cannot resolve
+	red := request.ProtoFile[2].EnumType[0].Value[1]
+	red.Options.ProtoReflect().SetUnknown(protowire.AppendString(protowire.AppendTag(nil, 50000, protowire.BytesType), "#ff0000"))
+
+	content := generateRequestFile(t, &request, "acme/paint/v1/pb_models.py")
+	want := `        "RED": {
+            "number": 1,
+            "description": "Bright red.",
+            "deprecated": True,
+            "options": {"acme.paint.v1.hex": "#ff0000"},
+        },
+`
+	if !strings.Contains(content, want) {
+		t.Errorf("generated module does not contain %q:\n%s", want, content)
+	}
+}
+
+const commentRequest = `
+file_to_generate: "acme/notes/v1/note.proto"
+proto_file {
+  name: "acme/notes/v1/note.proto"
+  package: "acme.notes.v1"
+  syntax: "proto3"
+  message_type {
+    name: "Note"
+    field { name: "title" number: 1 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "title" }
+  }
+  message_type { name: "Empty" }
+  source_code_info {
+    location { path: [4, 0] span: [2, 0, 4, 1] leading_comments: " A short note.\n" }
+    location { path: [4, 0, 2, 0] span: [3, 2, 19] leading_comments: " The \"title\" of the note.\n" }
+  }
+}
+`
+
+func TestGenerateComments(t *testing.T) {
+	for _, test := range []struct {
+		parameter string
+		want      []string
+	}{
+		{"", []string{
+			"class Note(BaseModel):\n    \"\"\"A short note.\"\"\"\n    title: str = Field(description=\"The \\\"title\\\" of the note.\")\n",
+			"class Empty(BaseModel):\n    pass\n",
+		}},
+		{"comment_style=hash", []string{
+			"# A short note.\nclass Note(BaseModel):\n    # The \"title\" of the note.\n    title: str = Field()\n",
+			"class Empty(BaseModel):\n    pass\n",
+		}},
+	} {
+		t.Run(test.parameter, func(t *testing.T) {
+			request := `parameter: "` + test.parameter + `"` + commentRequest
+			content := generateFile(t, request, "acme/notes/v1/pb_models.py")
+			for _, want := range test.want {
+				if !strings.Contains(content, want) {
+					t.Errorf("generated module does not contain %q:\n%s", want, content)
+				}
+			}
+		})
+	}
+}
+
+const behaviorRequest = `
+file_to_generate: "acme/library/v1/book.proto"
+proto_file {
+  name: "acme/library/v1/book.proto"
+  package: "acme.library.v1"
+  syntax: "proto3"
+  message_type {
+    name: "Book"
+    field { name: "name" number: 1 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "name" options { [google.api.field_behavior]: OUTPUT_ONLY } }
+    field { name: "title" number: 2 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "title" options { [google.api.field_behavior]: REQUIRED } }
+    field { name: "isbn" number: 3 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "isbn" options { [google.api.field_behavior]: IMMUTABLE } }
+    field { name: "secret" number: 4 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "secret" options { [google.api.field_behavior]: INPUT_ONLY } }
+    field { name: "chapters" number: 5 label: LABEL_REPEATED type: TYPE_MESSAGE type_name: ".acme.library.v1.Book.Chapter" json_name: "chapters" }
+    nested_type {
+      name: "Chapter"
+      field { name: "kind" number: 1 label: LABEL_OPTIONAL type: TYPE_ENUM type_name: ".acme.library.v1.Book.Chapter.Kind" json_name: "kind" options { [google.api.field_behavior]: REQUIRED } }
+      field { name: "title" number: 2 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "title" options { [google.api.field_behavior]: OUTPUT_ONLY } }
+      field { name: "style" number: 3 label: LABEL_OPTIONAL type: TYPE_ENUM type_name: ".acme.library.v1.Book.Chapter.Kind" json_name: "style" }
+      enum_type {
+        name: "Kind"
+        value { name: "KIND_UNSPECIFIED" number: 0 }
+        value { name: "PROSE" number: 1 }
+      }
+    }
+  }
+}
+`
+
+func TestGenerateFieldBehaviors(t *testing.T) {
+	content := generateFile(t, behaviorRequest, "acme/library/v1/pb_models.py")
+	for _, want := range []string{
+		// OUTPUT_ONLY fields may be omitted, as the server sets them
+		`name: Optional[str] = Field(default=None, json_schema_extra={"readOnly": True})`,
+		"title: str = Field()",
+		"isbn: str = Field(frozen=True)",
+		`secret: str = Field(exclude=True, json_schema_extra={"writeOnly": True})`,
+		"        # Behaviors: REQUIRED\n        kind: Kind = Field()\n",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("generated module does not contain %q:\n%s", want, content)
+		}
+	}
+}
+
+func TestGenerateNestedVariants(t *testing.T) {
+	request := `parameter: "input_variants,zero_defaults"` + behaviorRequest
+	content := generateFile(t, request, "acme/library/v1/pb_models.py")
+	for _, want := range []string{
+		"kind: Book.Chapter.Kind = Field()",
+		"style: Book.Chapter.Kind = Field(default=Book.Chapter.Kind.KIND_UNSPECIFIED)",
+		"Book.ChapterCreate = ChapterCreate\ndel ChapterCreate\n",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("generated module does not contain %q:\n%s", want, content)
+		}
+	}
+
+	// the nested types the variants reference must resolve on import
+	out := runGenerated(t, generateRequest(t, request), nil, `
+from acme.library.v1.pb_models import Book
+
+chapter = Book.ChapterCreate(kind=Book.Chapter.Kind.PROSE)
+print(chapter.style.name, Book.ChapterUpdate().kind)
+`)
+	if want := "KIND_UNSPECIFIED None\n"; out != want {
+		t.Errorf("variants print %q, want %q", out, want)
+	}
+}
+
+const resourceRequest = `
+file_to_generate: "acme/library/v1/shelf.proto"
+proto_file {
+  name: "acme/library/v1/shelf.proto"
+  package: "acme.library.v1"
+  syntax: "proto3"
+  message_type {
+    name: "Shelf"
+    field { name: "name" number: 1 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "name" }
+    field { name: "parent" number: 2 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "parent" options { [google.api.resource_reference] { type: "library.acme.com/Library" } } }
+    options { [google.api.resource] { type: "library.acme.com/Shelf" pattern: "libraries/{library}/shelves/{shelf}" } }
+  }
+  message_type {
+    name: "Library"
+    field { name: "name" number: 1 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "name" }
+    options { [google.api.resource] { type: "library.acme.com/Library" pattern: "libraries/{library}" } }
+  }
+}
+`
+
+func TestGenerateResourceNames(t *testing.T) {
+	content := generateFile(t, resourceRequest, "acme/library/v1/pb_models.py")
+	for _, want := range []string{
+		"class ShelfName(ResourceName):",
+		`__patterns__ = ("libraries/{library}/shelves/{shelf}",)`,
+		"    library: str = Field()\n    shelf: str = Field()\n",
+		// the reference checks names against the referenced resource
+		`def validate_parent_resource_name(cls, v):`,
+		`if not LibraryName.matches(name):`,
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("generated module does not contain %q:\n%s", want, content)
+		}
+	}
+
+	out := runGenerated(t, generateRequest(t, resourceRequest), nil, `
+from acme.library.v1.pb_models import LibraryName, ShelfName
+
+name = ShelfName.parse("libraries/main/shelves/fiction")
+print(name.library, name.shelf, LibraryName.matches("libraries/main/shelves/fiction"))
+`)
+	if want := "main fiction False\n"; out != want {
+		t.Errorf("resource names print %q, want %q", out, want)
+	}
+}
+
+const proto2Request = `
+file_to_generate: "acme/legacy/v1/legacy.proto"
+proto_file {
+  name: "acme/legacy/v1/legacy.proto"
+  package: "acme.legacy.v1"
+  syntax: "proto2"
+  message_type {
+    name: "Legacy"
+    field { name: "id" number: 1 label: LABEL_REQUIRED type: TYPE_STRING json_name: "id" }
+    field { name: "count" number: 2 label: LABEL_OPTIONAL type: TYPE_INT32 json_name: "count" default_value: "7" }
+    field { name: "magic" number: 3 label: LABEL_OPTIONAL type: TYPE_BYTES json_name: "magic" default_value: "\\377a\\\"\\303\\251" }
+    field { name: "grp" number: 4 label: LABEL_OPTIONAL type: TYPE_GROUP type_name: ".acme.legacy.v1.Legacy.Grp" json_name: "grp" }
+    nested_type {
+      name: "Grp"
+      field { name: "v" number: 1 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "v" }
+    }
+  }
+}
+`
+
+func TestGenerateProto2(t *testing.T) {
+	content := generateFile(t, proto2Request, "acme/legacy/v1/pb_models.py")
+	for _, want := range []string{
+		"id: str = Field()",
+		"count: int = Field(default=7)",
+		// the invalid byte decodes to a surrogate, as bytes fields do
+		`magic: str = Field(default="\udcffa\"é")`,
+		"    class Grp(BaseModel):\n",
+		"grp: Optional[Grp] = Field(default=None)",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("generated module does not contain %q:\n%s", want, content)
+		}
+	}
+
+	out := runGenerated(t, generateRequest(t, proto2Request), nil, `
+from acme.legacy.v1.pb_models import Legacy
+
+print(Legacy(id="x").magic == b"\xffa\"\xc3\xa9".decode("utf-8", "surrogateescape"))
+`)
+	if out != "True\n" {
+		t.Errorf("the bytes default does not decode the proto default: %q", out)
+	}
+}
+
+const editionsRequest = `
+file_to_generate: "acme/modern/v1/item.proto"
+proto_file {
+  name: "acme/modern/v1/item.proto"
+  package: "acme.modern.v1"
+  syntax: "editions"
+  edition: EDITION_2023
+  message_type {
+    name: "Item"
+    field { name: "sku" number: 1 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "sku" }
+    field { name: "count" number: 2 label: LABEL_OPTIONAL type: TYPE_INT32 json_name: "count" options { features { field_presence: IMPLICIT } } }
+    field { name: "id" number: 3 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "id" options { features { field_presence: LEGACY_REQUIRED } } }
+    field { name: "detail" number: 4 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".acme.modern.v1.Detail" json_name: "detail" }
+  }
+  enum_type {
+    name: "Size"
+    value { name: "SMALL" number: 1 }
+    options { features { enum_type: CLOSED } }
+  }
+  message_type {
+    name: "Detail"
+    field { name: "note" number: 1 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "note" }
+  }
+}
+`
+
+func TestGenerateEditions(t *testing.T) {
+	content := generateFile(t, editionsRequest, "acme/modern/v1/pb_models.py")
+	for _, want := range []string{
+		// fields have explicit presence unless their features say otherwise
+		"sku: Optional[str] = Field(default=None)",
+		"count: int = Field()",
+		"id: str = Field()",
+		// message fields are generated like proto3 ones
+		"detail: Detail = Field()",
+		"__proto_closed__ = True",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("generated module does not contain %q:\n%s", want, content)
+		}
+	}
+}
+
+const profileRequest = `
+file_to_generate: "acme/stats/v1/stats.proto"
+parameter: "profile.warehouse=maps_as_records+enums_as_int"
+proto_file {
+  name: "acme/stats/v1/stats.proto"
+  package: "acme.stats.v1"
+  syntax: "proto3"
+  enum_type {
+    name: "Level"
+    value { name: "LEVEL_UNSPECIFIED" number: 0 }
+  }
+  message_type {
+    name: "Stats"
+    field { name: "counts" number: 1 label: LABEL_REPEATED type: TYPE_MESSAGE type_name: ".acme.stats.v1.Stats.CountsEntry" json_name: "counts" }
+    field { name: "level" number: 2 label: LABEL_OPTIONAL type: TYPE_ENUM type_name: ".acme.stats.v1.Level" json_name: "level" }
+    nested_type {
+      name: "CountsEntry"
+      field { name: "key" number: 1 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "key" }
+      field { name: "value" number: 2 label: LABEL_OPTIONAL type: TYPE_INT64 json_name: "value" }
+      options { map_entry: true }
+    }
+  }
+}
+`
+
+func TestGenerateSerializationProfiles(t *testing.T) {
+	content := generateFile(t, profileRequest, "acme/stats/v1/pb_models.py")
+	for _, want := range []string{
+		`"warehouse": frozenset({"maps_as_records", "enums_as_int"}),`,
+		"        \"counts\": (\"map\",),\n        \"level\": (\"enum\",),\n",
+		"return _serialize_with_profile(v, handler, info, self.__serialization_kinds__[info.field_name])",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("generated module does not contain %q:\n%s", want, content)
+		}
+	}
+	if strings.Contains(content, `"bigquery"`) {
+		t.Errorf("the default profile is generated with a profile parameter:\n%s", content)
+	}
+
+	out := runGenerated(t, generateRequest(t, profileRequest), nil, `
+from acme.stats.v1.pb_models import Level, _serialize_with_profile
+
+
+class Info:
+    def __init__(self, context):
+        self.context = context
+
+
+def handler(v):
+    return v
+
+
+print(_serialize_with_profile({"a": 1}, handler, Info("warehouse"), ("map",)))
+print(_serialize_with_profile(Level.LEVEL_UNSPECIFIED, handler, Info("warehouse"), ("enum",)))
+print(_serialize_with_profile({"a": 1}, handler, Info(None), ("map",)))
+`)
+	if want := "[{'key': 'a', 'value': 1}]\n0\n{'a': 1}\n"; out != want {
+		t.Errorf("profiles serialize %q, want %q", out, want)
+	}
+
+	var request pluginpb.CodeGeneratorRequest
+	if err := prototext.Unmarshal([]byte(profileRequest), &request); err != nil {
+		t.Fatalf("unmarshal request: %v", err)
+	}
+	request.Parameter = proto.String("profile.warehouse=maps_as_yaml")
+	if _, err := Generate(&request); err == nil || !strings.Contains(err.Error(), `unknown transform "maps_as_yaml"`) {
+		t.Errorf("Generate with an unknown transform: %v", err)
+	}
+}
+
+const bigQueryRequest = `
+file_to_generate: "acme/events/v1/event.proto"
+proto_file {
+  name: "acme/events/v1/event.proto"
+  package: "acme.events.v1"
+  syntax: "proto3"
+  message_type {
+    name: "Event"
+    field { name: "id" number: 1 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "id" options { [google.api.field_behavior]: REQUIRED } }
+    field { name: "payload" number: 2 label: LABEL_OPTIONAL type: TYPE_BYTES json_name: "payload" }
+    field { name: "counts" number: 3 label: LABEL_REPEATED type: TYPE_INT64 json_name: "counts" }
+    field { name: "token" number: 4 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "token" options { [google.api.field_behavior]: INPUT_ONLY } }
+    options { [py_validate.bigquery_table]: true }
+  }
+  source_code_info {
+    location { path: [4, 0, 2, 1] span: [3, 2, 19] leading_comments: " Raw event body.\n" }
+  }
+}
+`
+
+func TestGenerateBigQuerySchema(t *testing.T) {
+	content := generateFile(t, bigQueryRequest, "acme/events/v1/Event.schema.json")
+	// bytes are dumped as text, and INPUT_ONLY fields are not dumped
+	want := `[
+  {
+    "name": "id",
+    "type": "STRING",
+    "mode": "REQUIRED"
+  },
+  {
+    "name": "payload",
+    "type": "STRING",
+    "mode": "NULLABLE",
+    "description": "Raw event body."
+  },
+  {
+    "name": "counts",
+    "type": "INTEGER",
+    "mode": "REPEATED"
+  }
+]`
+	if strings.TrimSpace(content) != want {
+		t.Errorf("schema = %s, want %s", content, want)
+	}
+}
+
+func TestGenerateJSONSchema(t *testing.T) {
+	for _, test := range []struct {
+		parameter, file, defs, prefix string
+	}{
+		{"json_schema", "acme/library/v1/pb_models.schema.json", "$defs", "#/$defs/"},
+		{"json_schema=openapi", "acme/library/v1/pb_models.openapi.json", "components", "#/components/schemas/"},
+	} {
+		t.Run(test.parameter, func(t *testing.T) {
+			content := generateFile(t, `parameter: "`+test.parameter+`"`+behaviorRequest, test.file)
+			var document map[string]any
+			if err := json.Unmarshal([]byte(content), &document); err != nil {
+				t.Fatalf("unmarshal %s: %v", test.file, err)
+			}
+			defs, _ := document[test.defs].(map[string]any)
+			if schemas, ok := defs["schemas"].(map[string]any); ok {
+				if document["openapi"] != "3.1.0" {
+					t.Errorf("openapi = %v, want 3.1.0", document["openapi"])
+				}
+				defs = schemas
+			}
+			book, _ := defs["acme.library.v1.Book"].(map[string]any)
+			if got := fmt.Sprint(book["required"]); got != "[title isbn secret]" {
+				t.Errorf("Book requires %s, want [title isbn secret]", got)
+			}
+			properties, _ := book["properties"].(map[string]any)
+			chapters, _ := properties["chapters"].(map[string]any)
+			items, _ := chapters["items"].(map[string]any)
+			if want := test.prefix + "acme.library.v1.Book.Chapter"; items["$ref"] != want {
+				t.Errorf("chapters reference %v, want %s", items["$ref"], want)
+			}
+			name, _ := properties["name"].(map[string]any)
+			if name["readOnly"] != true {
+				t.Errorf("the OUTPUT_ONLY name is not readOnly: %v", name)
+			}
+		})
+	}
+}
+
+func TestGenerateStrictSchema(t *testing.T) {
+	request := `parameter: "strict_schema"` + behaviorRequest
+	content := generateFile(t, request, "acme/library/v1/pb_models.py")
+	if !strings.Contains(content, `"$ref\":\"#/$defs/acme_library_v1_Book_Chapter\"`) {
+		t.Errorf("the strict schema of Book does not reference Chapter:\n%s", content)
+	}
+
+	out := runGenerated(t, generateRequest(t, request), nil, `
+from acme.library.v1.pb_models import Book, strict_json_schema
+
+schema = strict_json_schema(Book)
+print(schema["additionalProperties"], schema["required"])
+print(schema["properties"]["name"])
+`)
+	// every property is required, optional ones are nullable instead
+	want := "False ['name', 'title', 'isbn', 'secret', 'chapters']\n" +
+		"{'anyOf': [{'type': 'string'}, {'type': 'null'}]}\n"
+	if out != want {
+		t.Errorf("strict schema prints %q, want %q", out, want)
+	}
+
+	// maps have arbitrary keys, which strict schemas cannot describe
+	request = strings.Replace(profileRequest, `parameter: "`, `parameter: "strict_schema,`, 1)
+	content = generateFile(t, request, "acme/stats/v1/pb_models.py")
+	if want := `__strict_json_schema_error__ = "map field acme.stats.v1.Stats.counts has arbitrary keys"`; !strings.Contains(content, want) {
+		t.Errorf("generated module does not contain %q:\n%s", want, content)
+	}
+}
+
+const pb2Request = `
+file_to_generate: "acme/counts/v1/counter.proto"
+parameter: "pb2_module=gen"
+proto_file {
+  name: "acme/counts/v1/counter.proto"
+  package: "acme.counts.v1"
+  syntax: "proto3"
+  message_type {
+    name: "Counter"
+    field { name: "by_id" number: 1 label: LABEL_REPEATED type: TYPE_MESSAGE type_name: ".acme.counts.v1.Counter.ByIdEntry" json_name: "byId" }
+    field { name: "by_flag" number: 2 label: LABEL_REPEATED type: TYPE_MESSAGE type_name: ".acme.counts.v1.Counter.ByFlagEntry" json_name: "byFlag" }
+    nested_type {
+      name: "ByIdEntry"
+      field { name: "key" number: 1 label: LABEL_OPTIONAL type: TYPE_INT64 json_name: "key" }
+      field { name: "value" number: 2 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "value" }
+      options { map_entry: true }
+    }
+    nested_type {
+      name: "ByFlagEntry"
+      field { name: "key" number: 1 label: LABEL_OPTIONAL type: TYPE_BOOL json_name: "key" }
+      field { name: "value" number: 2 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "value" }
+      options { map_entry: true }
+    }
+  }
+}
+`
+
+// counterPb2 stands in for the module protoc generates for counter.proto,
+// with the parts of the descriptors the conversions read. Its maps reject
+// keys of the wrong type, as protobuf maps do.
+const counterPb2 = `
+class _Field:
+    TYPE_INT64, TYPE_BOOL, TYPE_STRING, TYPE_BYTES, TYPE_MESSAGE = 3, 8, 9, 12, 11
+    LABEL_OPTIONAL, LABEL_REPEATED = 1, 3
+
+    def __init__(self, name, type, label=LABEL_OPTIONAL, message_type=None):
+        self.name = name
+        self.type = type
+        self.label = label
+        self.message_type = message_type
+        self.enum_type = None
+        self.has_presence = False
+
+
+class _Options:
+    def __init__(self, map_entry):
+        self.map_entry = map_entry
+
+
+class _Descriptor:
+    def __init__(self, full_name, fields, map_entry=False):
+        self.full_name = full_name
+        self.fields = fields
+        self.fields_by_name = {f.name: f for f in fields}
+        self._options = _Options(map_entry)
+
+    def GetOptions(self):
+        return self._options
+
+
+class _Map(dict):
+    def __init__(self, key_type):
+        self.key_type = key_type
+
+    def __setitem__(self, key, value):
+        if not isinstance(key, self.key_type):
+            raise TypeError(f"{key!r} has type {type(key).__name__}, but expected {self.key_type.__name__}")
+        super().__setitem__(key, value)
+
+
+def _entry(name, key_type):
+    return _Descriptor(name, [_Field("key", key_type), _Field("value", _Field.TYPE_STRING)], map_entry=True)
+
+
+class Counter:
+    DESCRIPTOR = _Descriptor("acme.counts.v1.Counter", [
+        _Field("by_id", _Field.TYPE_MESSAGE, _Field.LABEL_REPEATED, _entry("ByIdEntry", _Field.TYPE_INT64)),
+        _Field("by_flag", _Field.TYPE_MESSAGE, _Field.LABEL_REPEATED, _entry("ByFlagEntry", _Field.TYPE_BOOL)),
+    ])
+
+    def __init__(self):
+        self.by_id = _Map(int)
+        self.by_flag = _Map(bool)
+
+    def HasField(self, name):
+        raise ValueError(f"{name} has no presence")
+`
+
+func TestGenerateProtoConversions(t *testing.T) {
+	content := generateFile(t, pb2Request, "acme/counts/v1/pb_models.py")
+	for _, want := range []string{
+		"import gen.acme.counts.v1.counter_pb2 as acme_counts_v1_counter_pb2",
+		"def to_proto(self) -> acme_counts_v1_counter_pb2.Counter:",
+		"def from_proto(cls, message: acme_counts_v1_counter_pb2.Counter) -> Self:",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("generated module does not contain %q:\n%s", want, content)
+		}
+	}
+
+	extra := map[string]string{"gen/acme/counts/v1/counter_pb2.py": counterPb2}
+	out := runGenerated(t, generateRequest(t, pb2Request), extra, `
+from acme.counts.v1.pb_models import Counter
+
+counter = Counter(by_id={"-7": "a", "8": "b"}, by_flag={"true": "yes", "false": "no"})
+message = counter.to_proto()
+print(dict(message.by_id), dict(message.by_flag))
+print(Counter.from_proto(message) == counter)
+`)
+	// models key maps by str, the _pb2 messages by the proto key type
+	if want := "{-7: 'a', 8: 'b'} {True: 'yes', False: 'no'}\nTrue\n"; out != want {
+		t.Errorf("conversions print %q, want %q", out, want)
+	}
+}
+
+func TestGenerateFileDescriptors(t *testing.T) {
+	request := `parameter: "descriptors"` + deterministicRequest
+	content := generateFile(t, request, "acme/shop/v1/pb_models.py")
+	for _, want := range []string{
+		`__proto_full_name__ = "acme.shop.v1.Order"`,
+		`__proto_field_numbers__ = {"buyer": 1, "items": 2}`,
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("generated module does not contain %q:\n%s", want, content)
+		}
+	}
+
+	out := runGenerated(t, generateRequest(t, request), nil, `
+from acme.shop.v1.pb_models import FILE_DESCRIPTORS
+
+for name, data in FILE_DESCRIPTORS.items():
+    print(name, data.hex())
+`)
+	var req pluginpb.CodeGeneratorRequest
+	if err := prototext.Unmarshal([]byte(request), &req); err != nil {
+		t.Fatalf("unmarshal request: %v", err)
+	}
+	files := make(map[string]*descriptorpb.FileDescriptorProto)
+	for _, file := range req.GetProtoFile() {
+		files[file.GetName()] = file
+	}
+	// the files of the package follow the files they import
+	var names []string
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		name, data, _ := strings.Cut(line, " ")
+		names = append(names, name)
+		b, err := hex.DecodeString(data)
+		if err != nil {
+			t.Fatalf("decode %s: %v", name, err)
+		}
+		var file descriptorpb.FileDescriptorProto
+		if err := proto.Unmarshal(b, &file); err != nil {
+			t.Fatalf("unmarshal %s: %v", name, err)
+		}
+		if !proto.Equal(&file, files[name]) {
+			t.Errorf("embedded %s = %v, want %v", name, &file, files[name])
+		}
+	}
+	if got, want := strings.Join(names, " "), "acme/shop/v1/user.proto acme/catalog/v1/item.proto acme/shop/v1/order.proto"; got != want {
+		t.Errorf("embedded files %s, want %s", got, want)
+	}
+}
+
+func TestGenerateModuleMapping(t *testing.T) {
+	request := `parameter: "module_prefix=gen,Macme/catalog/v1/item.proto=catalog.models"` + deterministicRequest
+	for name, want := range map[string]string{
+		"gen/acme/billing/v1/pb_models.py": "from gen.acme.shop.v1.pb_models import Order\n",
+		"gen/acme/shop/v1/pb_models.py":    "from catalog.models import Item\n",
+	} {
+		if content := generateFile(t, request, name); !strings.Contains(content, want) {
+			t.Errorf("%s does not contain %q:\n%s", name, want, content)
+		}
+	}
+
+	// the modules of a package import each other relatively
+	fileRequest := `parameter: "layout=file,module_prefix=gen"` + deterministicRequest
+	if content := generateFile(t, fileRequest, "gen/acme/shop/v1/order_pb_models.py"); !strings.Contains(content, "from .user_pb_models import User\n") {
+		t.Errorf("order_pb_models.py does not import User relatively:\n%s", content)
+	}
+
+	extra := map[string]string{"catalog/models.py": "from gen.acme.catalog.v1.pb_models import Item\n"}
+	out := runGenerated(t, generateRequest(t, request), extra, `
+from gen.acme.billing.v1 import Invoice
+from gen.acme.shop.v1 import Order
+
+print(Invoice.__module__, Order.__module__)
+`)
+	if want := "gen.acme.billing.v1.pb_models gen.acme.shop.v1.pb_models\n"; out != want {
+		t.Errorf("mapped modules print %q, want %q", out, want)
+	}
+}
+
+func TestGeneratePackageMapping(t *testing.T) {
+	// acme.catalog.v1 is not generated
+	request := strings.Replace(deterministicRequest, "file_to_generate: \"acme/catalog/v1/item.proto\"\n", "", 1)
+	var req pluginpb.CodeGeneratorRequest
+	if err := prototext.Unmarshal([]byte(request), &req); err != nil {
+		t.Fatalf("unmarshal request: %v", err)
+	}
+	_, err := Generate(&req)
+	want := "acme.shop.v1.Order.items references acme.catalog.v1.Item, which is neither generated nor mapped to a module: " +
+		"add acme/catalog/v1/item.proto to the files to generate or set Macme/catalog/v1/item.proto=<module> or Pacme.catalog.v1=<module>"
+	if err == nil || err.Error() != want {
+		t.Errorf("Generate without acme.catalog.v1 returns %v, want %s", err, want)
+	}
+
+	for _, parameter := range []string{
+		"Pacme.catalog.v1=catalog_wheel.models",
+		// an M parameter takes precedence over a P parameter
+		"Pacme.catalog.v1=other.models,Macme/catalog/v1/item.proto=catalog_wheel.models",
+	} {
+		content := generateFile(t, `parameter: "`+parameter+`"`+request, "acme/shop/v1/pb_models.py")
+		if want := "from catalog_wheel.models import Item\n"; !strings.Contains(content, want) {
+			t.Errorf("%s: generated module does not contain %q:\n%s", parameter, want, content)
+		}
+	}
+
+	mapped := `parameter: "Pacme.catalog.v1=catalog_wheel.models"` + request
+	extra := map[string]string{"catalog_wheel/models.py": `
+from pydantic import BaseModel
+
+
+class Item(BaseModel):
+    sku: str = ""
+`}
+	out := runGenerated(t, generateRequest(t, mapped), extra, `
+from acme.shop.v1 import Order
+
+print(Order.__annotations__["items"])
+`)
+	if want := "list[catalog_wheel.models.Item]\n"; out != want {
+		t.Errorf("Order.items is annotated %q, want %q", out, want)
+	}
+}
+
+func TestGeneratePackageHierarchy(t *testing.T) {
+	for _, tt := range []struct {
+		parameter string
+		want      map[string]bool
+	}{
+		{"module_prefix=gen", map[string]bool{
+			"gen/__init__.py": true, "gen/acme/__init__.py": true, "gen/acme/shop/__init__.py": true, "gen/acme/shop/v1/__init__.py": true,
+		}},
+		{"module_prefix=gen,namespace_packages", map[string]bool{
+			"gen/__init__.py": false, "gen/acme/__init__.py": false, "gen/acme/shop/__init__.py": false, "gen/acme/shop/v1/__init__.py": true,
+		}},
+	} {
+		files := make(map[string]bool)
+		for _, file := range generateRequest(t, `parameter: "`+tt.parameter+`"`+deterministicRequest).GetFile() {
+			files[file.GetName()] = true
+		}
+		for name, want := range tt.want {
+			if files[name] != want {
+				t.Errorf("%s: generates %s = %v, want %v", tt.parameter, name, files[name], want)
+			}
+		}
+	}
+
+	request := `parameter: "module_prefix=gen,root_init"` + deterministicRequest
+	content := generateFile(t, request, "gen/acme/shop/v1/pb_models.py")
+	if want := "__all__ = [\n    \"Order\",\n    \"ProtoEnum\",\n    \"Role\",\n    \"SERIALIZATION_PROFILES\",\n    \"User\",\n]\n"; !strings.Contains(content, want) {
+		t.Errorf("generated module does not contain %q:\n%s", want, content)
+	}
+	out := runGenerated(t, generateRequest(t, request), nil, `
+import gen
+
+print(gen.__all__)
+print(gen.acme_shop_v1.Order is gen.acme.shop.v1.Order)
+print(hasattr(gen.acme_shop_v1, "Item"))
+`)
+	// the package re-exports __all__, not the types it imports
+	if want := "['acme_billing_v1', 'acme_catalog_v1', 'acme_shop_v1']\nTrue\nFalse\n"; out != want {
+		t.Errorf("root package prints %q, want %q", out, want)
+	}
+}
+
+// generatedClasses returns the top-level classes of the generated modules
+// other than the helpers, by module.
+func generatedClasses(t *testing.T, request string) map[string][]string {
+	t.Helper()
+	classes := make(map[string][]string)
+	for _, file := range generateRequest(t, request).GetFile() {
+		if !strings.HasSuffix(file.GetName(), "pb_models.py") {
+			continue
+		}
+		classes[file.GetName()] = []string{}
+		for _, line := range strings.Split(file.GetContent(), "\n") {
+			name, ok := strings.CutPrefix(line, "class ")
+			if !ok || strings.HasPrefix(name, "ProtoEnum(") {
+				continue
+			}
+			classes[file.GetName()] = append(classes[file.GetName()], name[:strings.Index(name, "(")])
+		}
+	}
+	return classes
+}
+
+func TestGenerateIncludeExclude(t *testing.T) {
+	for _, tt := range []struct {
+		parameter string
+		want      string
+	}{
+		{"", "acme/billing/v1/pb_models.py: [Invoice] acme/catalog/v1/pb_models.py: [Item] acme/shop/v1/pb_models.py: [Role User Order]"},
+		{"include=acme/shop/v1/user.proto", "acme/shop/v1/pb_models.py: [Role User]"},
+		{"include=acme.shop.*,exclude=acme.shop.v1.Order", "acme/shop/v1/pb_models.py: [Role User]"},
+		{"include=acme.shop.v1.U*", "acme/shop/v1/pb_models.py: [Role User]"},
+		// the types Invoice depends on are generated, even when excluded
+		{"include=acme.billing.v1.Invoice,exclude=acme.shop.v1.*", "acme/billing/v1/pb_models.py: [Invoice] acme/catalog/v1/pb_models.py: [Item] acme/shop/v1/pb_models.py: [Role User Order]"},
+		{"include=acme.catalog.v1.Item,include=acme.shop.v1.Role", "acme/catalog/v1/pb_models.py: [Item] acme/shop/v1/pb_models.py: [Role]"},
+	} {
+		classes := generatedClasses(t, `parameter: "`+tt.parameter+`"`+deterministicRequest)
+		modules := make([]string, 0, len(classes))
+		for module := range classes {
+			modules = append(modules, module)
+		}
+		sort.Strings(modules)
+		got := make([]string, 0, len(modules))
+		for _, module := range modules {
+			got = append(got, fmt.Sprintf("%s: %v", module, classes[module]))
+		}
+		if strings.Join(got, " ") != tt.want {
+			t.Errorf("%q generates %s, want %s", tt.parameter, strings.Join(got, " "), tt.want)
+		}
+	}
+}
+
+const rootsRequest = `
+file_to_generate: "acme/store/v1/store.proto"
+proto_file {
+  name: "acme/store/v1/store.proto"
+  package: "acme.store.v1"
+  syntax: "proto3"
+  enum_type {
+    name: "Status"
+    value { name: "STATUS_UNSPECIFIED" number: 0 }
+  }
+  enum_type {
+    name: "Legacy"
+    value { name: "LEGACY_UNSPECIFIED" number: 0 }
+  }
+  message_type {
+    name: "GetOrderRequest"
+    field { name: "id" number: 1 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "id" }
+  }
+  message_type {
+    name: "Order"
+    field { name: "status" number: 1 label: LABEL_OPTIONAL type: TYPE_ENUM type_name: ".acme.store.v1.Status" json_name: "status" }
+    field { name: "lines" number: 2 label: LABEL_REPEATED type: TYPE_MESSAGE type_name: ".acme.store.v1.Line" json_name: "lines" }
+  }
+  message_type {
+    name: "Line"
+    field { name: "sku" number: 1 label: LABEL_OPTIONAL type: TYPE_STRING json_name: "sku" }
+  }
+  message_type {
+    name: "Audit"
+    field { name: "order" number: 1 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".acme.store.v1.Order" json_name: "order" }
+    field { name: "legacy" number: 2 label: LABEL_OPTIONAL type: TYPE_ENUM type_name: ".acme.store.v1.Legacy" json_name: "legacy" }
+  }
+  service {
+    name: "StoreService"
+    method { name: "GetOrder" input_type: ".acme.store.v1.GetOrderRequest" output_type: ".acme.store.v1.Order" }
+  }
+}
+`
+
+func TestGenerateRoots(t *testing.T) {
+	for _, tt := range []struct {
+		parameter string
+		want      string
+		stderr    string
+	}{
+		{"roots=acme.store.v1.StoreService", "[Status GetOrderRequest Line Order]", "pruned 2 of 6 messages and enums"},
+		{"roots=acme.store.v1.Order", "[Status Line Order]", "pruned 3 of 6 messages and enums"},
+		{"roots=acme.store.v1.Line,roots=acme.store.v1.Legacy", "[Legacy Line]", "pruned 4 of 6 messages and enums"},
+	} {
+		stderr := captureStderr(t, func() {
+			classes := generatedClasses(t, `parameter: "`+tt.parameter+`"`+rootsRequest)
+			if got := fmt.Sprint(classes["acme/store/v1/pb_models.py"]); got != tt.want {
+				t.Errorf("%q generates %s, want %s", tt.parameter, got, tt.want)
+			}
+		})
+		if !strings.Contains(stderr, tt.stderr) {
+			t.Errorf("%q reports %q, want %q", tt.parameter, stderr, tt.stderr)
+		}
+	}
+
+	for parameter, want := range map[string]string{
+		"roots=acme.store.v1.Missing":                       "find root acme.store.v1.Missing",
+		"roots=acme.store.v1.Order.status":                  "root acme.store.v1.Order.status is not a message, enum or service",
+		"roots=acme.store.v1.Order,include=acme.store.v1.*": "roots cannot be combined with include or exclude",
+	} {
+		var req pluginpb.CodeGeneratorRequest
+		if err := prototext.Unmarshal([]byte(`parameter: "`+parameter+`"`+rootsRequest), &req); err != nil {
+			t.Fatalf("unmarshal request: %v", err)
+		}
+		if _, err := Generate(&req); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q returns %v, want %s", parameter, err, want)
+		}
+	}
+}
+
+// captureStderr returns what fn writes to os.Stderr.
+func captureStderr(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr := os.Stderr
+	os.Stderr = w
+	defer func() { os.Stderr = stderr }()
+	fn()
+	w.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// stubScript prints the classes of a stub whose fields or __init__ differ
+// from the models of its module.
+const stubScript = `
+import ast
+import importlib
+import sys
+
+module = importlib.import_module(sys.argv[1])
+stub = ast.parse(open(sys.argv[2]).read())
+
+
+def check(node, scope):
+    for cls in node.body:
+        if not isinstance(cls, ast.ClassDef):
+            continue
+        model = getattr(scope, cls.name)
+        check(cls, model)
+        if not hasattr(model, "model_fields"):
+            continue
+        fields = [
+            stmt.target.id
+            for stmt in cls.body
+            if isinstance(stmt, ast.AnnAssign) and not ast.unparse(stmt.annotation).startswith("ClassVar")
+        ]
+        init = next(stmt for stmt in cls.body if isinstance(stmt, ast.FunctionDef) and stmt.name == "__init__")
+        params = [arg.arg for arg in init.args.kwonlyargs]
+        required = [arg.arg for arg, default in zip(init.args.kwonlyargs, init.args.kw_defaults) if default is None]
+        want = list(model.model_fields)
+        if fields != want or params != want or required != [k for k, v in model.model_fields.items() if v.is_required()]:
+            print(cls.name, fields, params, required)
+
+
+check(stub, module)
+print("checked")
+`
+
+func TestGenerateStubs(t *testing.T) {
+	request := `parameter: "stubs,input_variants"` + behaviorRequest
+	stub := generateFile(t, request, "acme/library/v1/pb_models.pyi")
+	for _, want := range []string{
+		"    def __init__(self, *, name: Optional[str] = ..., title: str, isbn: str, secret: str, chapters: list[Chapter] = ...) -> None: ...\n",
+		`kind: Kind | Literal["KIND_UNSPECIFIED", "PROSE"]`,
+		"    class ChapterCreate(BaseModel):\n",
+		"class BookUpdate(BaseModel):\n",
+	} {
+		if !strings.Contains(stub, want) {
+			t.Errorf("generated stub does not contain %q:\n%s", want, stub)
+		}
+	}
+
+	// the stubs declare the fields of the models, and the required ones
+	// without a default
+	out := runGenerated(t, generateRequest(t, request), nil, stubScript, "acme.library.v1.pb_models", "acme/library/v1/pb_models.pyi")
+	if out != "checked\n" {
+		t.Errorf("stub differs from the models:\n%s", out)
+	}
+
+	// with the file layout, the runtime module has a stub of its own
+	fileRequest := `parameter: "stubs,layout=file"` + deterministicRequest
+	for _, name := range []string{"acme/shop/v1/pb_models_runtime.pyi", "acme/shop/v1/order_pb_models.pyi"} {
+		generateFile(t, fileRequest, name)
+	}
+}
@@ -0,0 +1,347 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cortea-ai/protoc-gen-pydantic/internal/protowalk"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+	openAPIVersion    = "3.1.0"
+)
+
+var packageVersion = regexp.MustCompile(`^v\d+`)
+
+// jsonMember is a member of a jsonObject.
+type jsonMember struct {
+	key   string
+	value any
+}
+
+// jsonObject is a JSON object keeping the order of its members, so schemas
+// list properties in field order.
+type jsonObject []jsonMember
+
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, member := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(member.key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(member.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (o jsonObject) set(key string, value any) jsonObject {
+	return append(o, jsonMember{key: key, value: value})
+}
+
+// jsonSchemaGenerator writes the models of a package as a JSON Schema
+// document, or as the schemas of an OpenAPI document.
+type jsonSchemaGenerator struct {
+	pkg       protoreflect.FullName
+	files     []protoreflect.FileDescriptor
+	params    map[string]string
+	resources resourceNames
+	selected  selection
+}
+
+func (j jsonSchemaGenerator) openAPI() bool {
+	return j.params["json_schema"] == "openapi"
+}
+
+// extension returns the suffix of the document written next to the module.
+func (j jsonSchemaGenerator) extension() string {
+	if j.openAPI() {
+		return ".openapi.json"
+	}
+	return ".schema.json"
+}
+
+func (j jsonSchemaGenerator) ref(name string) jsonObject {
+	if j.openAPI() {
+		return jsonObject{{"$ref", "#/components/schemas/" + name}}
+	}
+	return jsonObject{{"$ref", "#/$defs/" + name}}
+}
+
+func (j jsonSchemaGenerator) Generate() ([]byte, error) {
+	defs := jsonObject{}
+	protowalk.WalkFiles(j.files, func(desc protoreflect.Descriptor) bool {
+		if !j.selected.has(desc) {
+			return true
+		}
+		switch t := desc.(type) {
+		case protoreflect.MessageDescriptor:
+			if t.IsMapEntry() || IsWellKnownType(t) {
+				return true
+			}
+			fields := make([]pydanticField, 0, t.Fields().Len())
+			rangeFields(t, func(field protoreflect.FieldDescriptor) {
+				fields = append(fields, newPydanticField(j.pkg, field, j.params))
+			})
+			name := string(t.Name())
+			description := commentGenerator{descriptor: t}.text()
+			defs = defs.set(string(t.FullName()), j.messageSchema(name, description, fields, true))
+			if boolParam(j.params, "input_variants") && hasFieldBehaviors(t) {
+				defs = defs.set(string(t.FullName())+"Create", j.messageSchema(name+"Create",
+					createVariantDescription(name), createVariantFields(fields), true))
+				defs = defs.set(string(t.FullName())+"Update", j.messageSchema(name+"Update",
+					updateVariantDescription(name), updateVariantFields(fields), false))
+			}
+		case protoreflect.EnumDescriptor:
+			if !IsWellKnownType(t) {
+				defs = defs.set(string(t.FullName()), j.enumSchema(t))
+			}
+		}
+		return true
+	})
+
+	var document jsonObject
+	if j.openAPI() {
+		version := "0"
+		if v := packageVersion.FindString(string(j.pkg.Name())); v != "" {
+			version = v
+		}
+		document = jsonObject{
+			{"openapi", openAPIVersion},
+			{"info", jsonObject{{"title", string(j.pkg)}, {"version", version}}},
+			{"components", jsonObject{{"schemas", defs}}},
+		}
+	} else {
+		document = jsonObject{
+			{"$schema", jsonSchemaDialect},
+			{"title", string(j.pkg)},
+			{"$defs", defs},
+		}
+	}
+	content, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshal JSON schema of %s: %w", j.pkg, err)
+	}
+	return append(content, '\n'), nil
+}
+
+func (j jsonSchemaGenerator) enumSchema(enum protoreflect.EnumDescriptor) jsonObject {
+	dropUnspecified := boolParam(j.params, "drop_unspecified")
+	// aliases are accepted by name too
+	names := make([]string, 0, enum.Values().Len())
+	rangeEnumValues(enum, func(value protoreflect.EnumValueDescriptor, _ bool) {
+		if dropUnspecified && isUnspecifiedValue(value) {
+			return
+		}
+		names = append(names, string(value.Name()))
+	})
+	schema := jsonObject{{"title", string(enum.Name())}}
+	if text := (commentGenerator{descriptor: enum}).text(); text != "" {
+		schema = schema.set("description", text)
+	}
+	return schema.set("type", "string").set("enum", names)
+}
+
+// messageSchema describes a model with the given fields. withOneOf adds the
+// constraint of the oneof validator, which the Update model does not have.
+func (j jsonSchemaGenerator) messageSchema(title, description string, fields []pydanticField, withOneOf bool) jsonObject {
+	schema := jsonObject{{"title", title}}
+	if description != "" {
+		schema = schema.set("description", description)
+	}
+	schema = schema.set("type", "object")
+
+	properties := jsonObject{}
+	required := make([]string, 0)
+	oneOf := make([]jsonObject, 0)
+	for _, pf := range fields {
+		name := string(pf.field.Name())
+		properties = properties.set(name, j.fieldSchema(pf))
+		if pf.defaultValue == "" && pf.defaultFactory == "" {
+			required = append(required, name)
+		}
+		if withOneOf && isOneOfField(pf.field) {
+			// exactly one of the oneof fields is set and not null
+			oneOf = append(oneOf, jsonObject{
+				{"required", []string{name}},
+				{"properties", jsonObject{{name, jsonObject{{"not", jsonObject{{"type", "null"}}}}}}},
+			})
+		}
+	}
+	schema = schema.set("properties", properties)
+	if len(required) > 0 {
+		schema = schema.set("required", required)
+	}
+	if len(oneOf) > 0 {
+		schema = schema.set("oneOf", oneOf)
+	}
+	return schema
+}
+
+func (j jsonSchemaGenerator) fieldSchema(pf pydanticField) jsonObject {
+	var schema jsonObject
+	switch {
+	case pf.field.IsMap():
+		schema = jsonObject{
+			{"type", "object"},
+			{"additionalProperties", j.valueSchema(pf.field.MapValue(), pf.isUUID)},
+		}
+	case pf.field.IsList():
+		items := j.valueSchema(pf.field, pf.isUUID)
+		if resource := j.resources.fieldResource(pf.field); resource != nil {
+			items = items.set("pattern", "^"+resource.regexp()+"$")
+		}
+		schema = jsonObject{{"type", "array"}, {"items", items}}
+	default:
+		schema = j.valueSchema(pf.field, pf.isUUID)
+		if resource := j.resources.fieldResource(pf.field); resource != nil {
+			// empty names are not validated
+			schema = schema.set("pattern", "^(?:|"+resource.regexp()+")$")
+		}
+	}
+	schema = append(schema, j.constraints(pf)...)
+
+	if pf.isOptional {
+		branches := []jsonObject{schema, {{"type", "null"}}}
+		if boolParam(j.params, "drop_unspecified") && pf.field.Enum() != nil {
+			if zero := pf.field.Enum().Values().ByNumber(0); zero != nil && isUnspecifiedValue(zero) {
+				// mapped to None by the unspecified validator
+				branches = append(branches, jsonObject{{"enum", []any{string(zero.Name()), 0}}})
+			}
+		}
+		schema = jsonObject{{"anyOf", branches}}
+	}
+	if value, ok := j.defaultValue(pf); ok {
+		schema = schema.set("default", value)
+	}
+	if pf.hasBehavior(annotations.FieldBehavior_OUTPUT_ONLY) {
+		schema = schema.set("readOnly", true)
+	}
+	if pf.hasBehavior(annotations.FieldBehavior_INPUT_ONLY) {
+		schema = schema.set("writeOnly", true)
+	}
+	if text := (commentGenerator{descriptor: pf.field}).text(); text != "" {
+		schema = schema.set("description", text)
+	}
+	return schema
+}
+
+// valueSchema describes a singular value of a field.
+func (j jsonSchemaGenerator) valueSchema(field protoreflect.FieldDescriptor, isUUID bool) jsonObject {
+	switch field.Kind() {
+	case protoreflect.StringKind, protoreflect.BytesKind:
+		if isUUID {
+			return jsonObject{{"type", "string"}, {"format", "uuid"}}
+		}
+		return jsonObject{{"type", "string"}}
+	case protoreflect.BoolKind:
+		return jsonObject{{"type", "boolean"}}
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return jsonObject{{"type", "number"}}
+	case protoreflect.EnumKind:
+		return j.ref(string(field.Enum().FullName()))
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		if wkt, ok := WellKnownType(field.Message()); ok {
+			switch wkt {
+			case WellKnownTimestamp:
+				return jsonObject{{"type", "string"}, {"format", "date-time"}}
+			case WellKnownDuration:
+				return jsonObject{{"type", "string"}, {"format", "duration"}}
+			}
+		}
+		return j.ref(string(field.Message().FullName()))
+	default:
+		return jsonObject{{"type", "integer"}}
+	}
+}
+
+// constraints translates the Field() constraints set by the py_validate
+// rules.
+func (j jsonSchemaGenerator) constraints(pf pydanticField) jsonObject {
+	constraints := jsonObject{}
+	for _, opt := range pf.opts {
+		name, value, _ := strings.Cut(opt, "=")
+		number := json.RawMessage(value)
+		switch name {
+		case "lt":
+			constraints = constraints.set("exclusiveMaximum", number)
+		case "lte":
+			constraints = constraints.set("maximum", number)
+		case "gt":
+			constraints = constraints.set("exclusiveMinimum", number)
+		case "gte":
+			constraints = constraints.set("minimum", number)
+		case "min_length":
+			if pf.field.IsList() {
+				constraints = constraints.set("minItems", number)
+			} else {
+				constraints = constraints.set("minLength", number)
+			}
+		case "max_length":
+			if pf.field.IsList() {
+				constraints = constraints.set("maxItems", number)
+			} else {
+				constraints = constraints.set("maxLength", number)
+			}
+		}
+		// len is not a constraint Pydantic validates
+	}
+	return constraints
+}
+
+// defaultValue returns the JSON value of the default of a field, if it has
+// one that can be written as JSON.
+func (j jsonSchemaGenerator) defaultValue(pf pydanticField) (any, bool) {
+	if pf.defaultFactory != "" {
+		switch {
+		case pf.field.IsList():
+			return []any{}, true
+		case pf.field.IsMap():
+			return jsonObject{}, true
+		case pf.defaultFactory == "default_factory="+pf.fieldType.Reference(false):
+			return jsonObject{}, true
+		}
+		return nil, false
+	}
+	if pf.defaultEnum != nil && pf.defaultValue == pf.enumDefault() {
+		return string(primaryEnumValue(pf.defaultEnum, boolParam(j.params, "drop_unspecified")).Name()), true
+	}
+	value, ok := strings.CutPrefix(pf.defaultValue, "default=")
+	if !ok {
+		return nil, false
+	}
+	switch value {
+	case "None":
+		return nil, true
+	case "True":
+		return true, true
+	case "False":
+		return false, true
+	}
+	if s, err := strconv.Unquote(value); err == nil {
+		return s, true
+	}
+	if json.Valid([]byte(value)) {
+		return json.RawMessage(value), true
+	}
+	// float("inf"), float("nan") and bytes literals have no JSON form
+	return nil, false
+}
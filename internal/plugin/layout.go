@@ -0,0 +1,166 @@
+package plugin
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/cortea-ai/protoc-gen-pydantic/internal/codegen"
+	"github.com/cortea-ai/protoc-gen-pydantic/internal/protowalk"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	// layoutPackage generates one module per package.
+	layoutPackage = "package"
+	// layoutFile generates one module per proto file of a package.
+	layoutFile = "file"
+)
+
+func parseLayout(params map[string]string) (string, error) {
+	switch layout := params["layout"]; layout {
+	case "", layoutPackage:
+		return layoutPackage, nil
+	case layoutFile:
+		return layoutFile, nil
+	default:
+		return "", fmt.Errorf("unknown layout %q", layout)
+	}
+}
+
+// fileModules returns the module generated for each file of a package with
+// the file layout, named after the proto file: user.proto generates
+// user_<filename>.
+func fileModules(files []protoreflect.FileDescriptor, filename string) (map[string]string, error) {
+	modules := make(map[string]string, len(files))
+	paths := make(map[string]string, len(files))
+	for _, file := range files {
+		module := strings.ReplaceAll(strings.TrimSuffix(path.Base(file.Path()), ".proto"), "-", "_") + "_" + filename
+		if other, ok := paths[module]; ok {
+			return nil, fmt.Errorf("files %s and %s both generate module %s", other, file.Path(), module)
+		}
+		if module == runtimeModule(filename) {
+			return nil, fmt.Errorf("file %s generates module %s, which is the runtime module of its package", file.Path(), module)
+		}
+		paths[module] = file.Path()
+		modules[file.Path()] = module
+	}
+	return modules, nil
+}
+
+// runtimeModule returns the module declaring the helpers shared by the
+// modules of a package with the file layout.
+func runtimeModule(filename string) string {
+	return filename + "_runtime"
+}
+
+// moduleFiles returns the files whose types the module declares.
+func (p packageGenerator) moduleFiles() []protoreflect.FileDescriptor {
+	if p.file != nil {
+		return []protoreflect.FileDescriptor{p.file}
+	}
+	return p.files
+}
+
+// importedModule returns the module a type declared by another module is
+// imported from, if the type is not generated in this module.
+func (p packageGenerator) importedModule(desc protoreflect.Descriptor) (string, bool) {
+	path := desc.ParentFile().Path()
+	for _, file := range p.moduleFiles() {
+		if file.Path() == path {
+			return "", false
+		}
+	}
+	module, ok := p.modules.module(desc.ParentFile())
+	if !ok || module == p.module {
+		return "", false
+	}
+	return relativeModule(module, p.module), true
+}
+
+// topLevelDescriptor returns the top-level message or enum declaring a
+// possibly nested type.
+func topLevelDescriptor(desc protoreflect.Descriptor) protoreflect.Descriptor {
+	for {
+		parent, ok := desc.Parent().(protoreflect.MessageDescriptor)
+		if !ok {
+			return desc
+		}
+		desc = parent
+	}
+}
+
+// topLevelName returns the name of the top-level message or enum declaring a
+// possibly nested type.
+func topLevelName(desc protoreflect.Descriptor) string {
+	return string(topLevelDescriptor(desc).Name())
+}
+
+// rangeReferences calls fn with the messages and enums referenced by the
+// fields of the types generated in the module, except well known types.
+func (p packageGenerator) rangeReferences(fn func(field protoreflect.FieldDescriptor, target protoreflect.Descriptor)) {
+	protowalk.WalkFiles(p.moduleFiles(), func(desc protoreflect.Descriptor) bool {
+		field, ok := desc.(protoreflect.FieldDescriptor)
+		if !ok {
+			return true
+		}
+		if _, ok := p.importedModule(field.ContainingMessage()); ok || !p.selected.has(field.ContainingMessage()) {
+			return true
+		}
+		for _, target := range []protoreflect.Descriptor{field.Message(), field.Enum()} {
+			if target != nil && !IsWellKnownType(target) {
+				fn(field, target)
+			}
+		}
+		return true
+	})
+}
+
+// checkReferences returns an error if the module references a type of
+// another package that is neither generated nor mapped to a module.
+func (p packageGenerator) checkReferences() error {
+	var err error
+	p.rangeReferences(func(field protoreflect.FieldDescriptor, target protoreflect.Descriptor) {
+		file := target.ParentFile()
+		if err != nil || file.Package() == p.pkg {
+			return
+		}
+		if _, ok := p.modules.module(file); !ok {
+			err = fmt.Errorf("%s references %s, which is neither generated nor mapped to a module: "+
+				"add %s to the files to generate or set M%s=<module> or P%s=<module>",
+				field.FullName(), target.FullName(), file.Path(), file.Path(), file.Package())
+		}
+	})
+	return err
+}
+
+// generateModuleImports imports the types declared by other modules that the
+// fields of the module reference.
+func (p packageGenerator) generateModuleImports(f *codegen.File) {
+	imported := make(map[string]map[string]struct{})
+	p.rangeReferences(func(_ protoreflect.FieldDescriptor, target protoreflect.Descriptor) {
+		if module, ok := p.importedModule(target); ok {
+			if imported[module] == nil {
+				imported[module] = make(map[string]struct{})
+			}
+			imported[module][topLevelName(target)] = struct{}{}
+		}
+	})
+	modules := make([]string, 0, len(imported))
+	for module := range imported {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+	for _, module := range modules {
+		names := make([]string, 0, len(imported[module]))
+		for name := range imported[module] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		f.P("from ", module, " import ", strings.Join(names, ", "))
+	}
+	if len(modules) > 0 {
+		f.P()
+	}
+}
@@ -0,0 +1,137 @@
+package plugin
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// pythonValue renders the value of a field as a Python literal.
+func pythonValue(field protoreflect.FieldDescriptor, v protoreflect.Value) string {
+	switch {
+	case field.IsList():
+		list := v.List()
+		items := make([]string, 0, list.Len())
+		for i := 0; i < list.Len(); i++ {
+			items = append(items, pythonScalar(field, list.Get(i)))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case field.IsMap():
+		items := make([]string, 0, v.Map().Len())
+		v.Map().Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+			items = append(items, pythonScalar(field.MapKey(), k.Value())+": "+pythonScalar(field.MapValue(), v))
+			return true
+		})
+		sort.Strings(items)
+		return "{" + strings.Join(items, ", ") + "}"
+	default:
+		return pythonScalar(field, v)
+	}
+}
+
+func pythonScalar(field protoreflect.FieldDescriptor, v protoreflect.Value) string {
+	switch field.Kind() {
+	case protoreflect.BoolKind:
+		if v.Bool() {
+			return "True"
+		}
+		return "False"
+	case protoreflect.StringKind:
+		return strconv.Quote(v.String())
+	case protoreflect.BytesKind:
+		return pythonBytes(v.Bytes())
+	case protoreflect.EnumKind:
+		if value := field.Enum().Values().ByNumber(v.Enum()); value != nil {
+			return strconv.Quote(string(value.Name()))
+		}
+		return strconv.Itoa(int(v.Enum()))
+	case protoreflect.FloatKind:
+		return pythonFloat(v.Float(), 32)
+	case protoreflect.DoubleKind:
+		return pythonFloat(v.Float(), 64)
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return pythonMessage(v.Message())
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
+
+// pythonMessage renders a message as a dict keyed by field name.
+func pythonMessage(msg protoreflect.Message) string {
+	items := make([]string, 0)
+	fields := msg.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		if !msg.Has(field) {
+			continue
+		}
+		items = append(items, strconv.Quote(string(field.Name()))+": "+pythonValue(field, msg.Get(field)))
+	}
+	return "{" + strings.Join(items, ", ") + "}"
+}
+
+func pythonFloat(f float64, bitSize int) string {
+	switch {
+	case math.IsInf(f, 1):
+		return `float("inf")`
+	case math.IsInf(f, -1):
+		return `float("-inf")`
+	case math.IsNaN(f):
+		return `float("nan")`
+	}
+	s := strconv.FormatFloat(f, 'g', -1, bitSize)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
+// pythonSurrogateString renders bytes as a Python str literal of their
+// decoding with errors="surrogateescape", the way models hold bytes fields.
+func pythonSurrogateString(b []byte) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for len(b) > 0 {
+		valid := 0
+		for valid < len(b) {
+			r, size := utf8.DecodeRune(b[valid:])
+			if r == utf8.RuneError && size == 1 {
+				break
+			}
+			valid += size
+		}
+		quoted := strconv.Quote(string(b[:valid]))
+		sb.WriteString(quoted[1 : len(quoted)-1])
+		if valid < len(b) {
+			// an invalid byte decodes to a lone surrogate
+			fmt.Fprintf(&sb, `\udc%02x`, b[valid])
+			valid++
+		}
+		b = b[valid:]
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+func pythonBytes(b []byte) string {
+	var sb strings.Builder
+	sb.WriteString(`b"`)
+	for _, c := range b {
+		switch {
+		case c == '"' || c == '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case c >= 0x20 && c < 0x7f:
+			sb.WriteByte(c)
+		default:
+			fmt.Fprintf(&sb, `\x%02x`, c)
+		}
+	}
+	sb.WriteString(`"`)
+	return sb.String()
+}
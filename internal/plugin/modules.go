@@ -0,0 +1,95 @@
+package plugin
+
+import (
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// moduleMap locates the Python module declaring the types of each proto
+// file: an existing module it is mapped to with an M<file>=<module>
+// parameter, or its package with a P<package>=<module> parameter, or the
+// module generated for it.
+type moduleMap struct {
+	prefix    string
+	suffix    string
+	generated map[string]string
+	mapped    map[string]string
+	packages  map[protoreflect.FullName]string
+}
+
+func newModuleMap(packaged map[protoreflect.FullName][]protoreflect.FileDescriptor, params map[string]string, filename, layout string) (moduleMap, error) {
+	m := moduleMap{
+		prefix:    strings.TrimSuffix(params["module_prefix"], "."),
+		suffix:    params["package_suffix"],
+		generated: make(map[string]string),
+		mapped:    make(map[string]string),
+		packages:  make(map[protoreflect.FullName]string),
+	}
+	for key, value := range params {
+		if value == "" {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(key, "M"):
+			m.mapped[strings.TrimPrefix(key, "M")] = value
+		case strings.HasPrefix(key, "P"):
+			m.packages[protoreflect.FullName(strings.TrimPrefix(key, "P"))] = value
+		}
+	}
+	for _, pkg := range sortedPackages(packaged) {
+		files := packaged[pkg]
+		if layout == layoutFile {
+			modules, err := fileModules(files, filename)
+			if err != nil {
+				return moduleMap{}, err
+			}
+			for path, module := range modules {
+				m.generated[path] = m.packageModule(pkg) + "." + module
+			}
+			continue
+		}
+		for _, file := range files {
+			m.generated[file.Path()] = m.packageModule(pkg) + "." + filename
+		}
+	}
+	return m, nil
+}
+
+// packageModule returns the Python package generated for a proto package.
+func (m moduleMap) packageModule(pkg protoreflect.FullName) string {
+	module := string(pkg) + m.suffix
+	if m.prefix != "" {
+		module = m.prefix + "." + module
+	}
+	return module
+}
+
+// packageDir returns the output directory of a proto package.
+func (m moduleMap) packageDir(pkg protoreflect.FullName) []string {
+	return strings.Split(m.packageModule(pkg), ".")
+}
+
+// module returns the module declaring the types of a file, preferring an
+// existing module mapped with an M or P parameter over a generated one.
+func (m moduleMap) module(file protoreflect.FileDescriptor) (string, bool) {
+	if module, ok := m.mapped[file.Path()]; ok {
+		return module, true
+	}
+	if module, ok := m.packages[file.Package()]; ok {
+		return module, true
+	}
+	module, ok := m.generated[file.Path()]
+	return module, ok
+}
+
+// relativeModule returns how module is imported from the module from: with a
+// relative import within the same Python package.
+func relativeModule(module, from string) string {
+	i := strings.LastIndex(module, ".")
+	j := strings.LastIndex(from, ".")
+	if i >= 0 && j >= 0 && module[:i] == from[:j] {
+		return module[i:]
+	}
+	return module
+}
@@ -0,0 +1,58 @@
+package plugin
+
+import (
+	"sort"
+	"strconv"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// extensionTypes collects the extensions declared by every file of the
+// request, so custom options unknown to the plugin binary can be decoded.
+func extensionTypes(files *protoregistry.Files) *protoregistry.Types {
+	types := new(protoregistry.Types)
+	register := func(extensions protoreflect.ExtensionDescriptors) {
+		for i := 0; i < extensions.Len(); i++ {
+			// duplicates are impossible within a single registry
+			_ = types.RegisterExtension(dynamicpb.NewExtensionType(extensions.Get(i)))
+		}
+	}
+	var registerMessages func(messages protoreflect.MessageDescriptors)
+	registerMessages = func(messages protoreflect.MessageDescriptors) {
+		for i := 0; i < messages.Len(); i++ {
+			register(messages.Get(i).Extensions())
+			registerMessages(messages.Get(i).Messages())
+		}
+	}
+	files.RangeFiles(func(file protoreflect.FileDescriptor) bool {
+		register(file.Extensions())
+		registerMessages(file.Messages())
+		return true
+	})
+	return types
+}
+
+// customOptions renders the extensions set on an options message as Python
+// dict items keyed by the extension full name.
+func customOptions(opts proto.Message, types *protoregistry.Types) []string {
+	b, err := proto.Marshal(opts)
+	if err != nil {
+		return nil
+	}
+	resolved := opts.ProtoReflect().New()
+	if err := (proto.UnmarshalOptions{Resolver: types}).Unmarshal(b, resolved.Interface()); err != nil {
+		return nil
+	}
+	items := make([]string, 0)
+	resolved.Range(func(field protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if field.IsExtension() {
+			items = append(items, strconv.Quote(string(field.FullName()))+": "+pythonValue(field, v))
+		}
+		return true
+	})
+	sort.Strings(items)
+	return items
+}
@@ -6,12 +6,27 @@ import (
 	"github.com/cortea-ai/protoc-gen-pydantic/internal/codegen"
 	"github.com/cortea-ai/protoc-gen-pydantic/internal/protowalk"
 	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
 )
 
 type packageGenerator struct {
-	pkg    protoreflect.FullName
-	files  []protoreflect.FileDescriptor
-	params map[string]string
+	pkg      protoreflect.FullName
+	files    []protoreflect.FileDescriptor
+	params   map[string]string
+	types    *protoregistry.Types
+	profiles serializationProfiles
+	target   pythonTarget
+	// file is the file the module is generated for with the file layout.
+	file protoreflect.FileDescriptor
+	// module is the generated module, modules where the types of other
+	// files are imported from.
+	module  string
+	modules moduleMap
+	// runtime is the module declaring the helpers shared by the modules of
+	// the package with the file layout, empty if the module declares them.
+	runtime string
+	// selected holds the types to generate, nil for every type.
+	selected selection
 }
 
 type descNode struct {
@@ -20,13 +35,82 @@ type descNode struct {
 	children  []*descNode
 }
 
-func (p packageGenerator) Generate(f *codegen.File) {
-	p.generateHeader(f)
+func (p packageGenerator) Generate(f *codegen.File) error {
+	resources := packageResources(p.pkg, p.files)
+	p.generateHeader(f, resources)
+	if p.runtime == "" {
+		if err := p.generateHelpers(f, resources); err != nil {
+			return err
+		}
+	}
+
+	p.rangeDescriptorTrees(resources, func(node *descNode) {
+		node.generator.GenerateHeader(f)
+
+		var visitChildren func(node *descNode)
+		visitChildren = func(node *descNode) {
+			for _, child := range node.children {
+				child.generator.GenerateHeader(f)
+				visitChildren(child)
+				child.generator.GenerateFields(f)
+			}
+		}
+		visitChildren(node)
+
+		node.generator.GenerateFields(f)
+		f.P()
+
+		var visitVariants func(node *descNode)
+		visitVariants = func(node *descNode) {
+			node.generator.GenerateVariants(f)
+			for _, child := range node.children {
+				visitVariants(child)
+			}
+		}
+		visitVariants(node)
+	})
+	return nil
+}
+
+// GenerateRuntime emits the module declaring the helpers shared by the
+// modules of a package with the file layout, so that every module uses the
+// same ProtoEnum, resource names and runtimes.
+func (p packageGenerator) GenerateRuntime(f *codegen.File) error {
+	resources := packageResources(p.pkg, p.files)
+	p.generateImports(f, resources)
+	p.generateExports(f, p.helperExports(resources))
+	return p.generateHelpers(f, resources)
+}
+
+// generateHelpers emits the base classes, resource names and runtimes the
+// models use.
+func (p packageGenerator) generateHelpers(f *codegen.File, resources resourceNames) error {
+	p.generateEnumBase(f)
+	resources.generate(f, p.target)
+	p.profiles.generate(f, p.target)
+	if boolParam(p.params, "strict_schema") {
+		generateStrictSchemaHelper(f, p.target)
+	}
+	if _, ok := p.params["pb2_module"]; ok {
+		generatePb2Helpers(f)
+	}
+	if boolParam(p.params, "wire_format") {
+		generateWireRuntime(f, p.target)
+	}
+	return p.generateFileDescriptors(f)
+}
 
+// rangeDescriptorTrees calls fn with each top-level message and enum of the
+// module, in declaration order after their dependencies, with their nested
+// types as children.
+func (p packageGenerator) rangeDescriptorTrees(resources resourceNames, fn func(node *descNode)) {
 	root := &descNode{name: "root", children: []*descNode{}}
 	current := root
 
-	protowalk.WalkFiles(p.files, func(desc protoreflect.Descriptor) bool {
+	protowalk.WalkFiles(p.moduleFiles(), func(desc protoreflect.Descriptor) bool {
+		if _, ok := p.importedModule(desc); ok || !p.selected.has(desc) {
+			return true
+		}
 		switch t := desc.(type) {
 		case protoreflect.MessageDescriptor:
 			if t.IsMapEntry() {
@@ -53,10 +137,15 @@ func (p packageGenerator) Generate(f *codegen.File) {
 			isLeaf := i == len(parts)-1
 			if isLeaf {
 				g = descriptorGenerator{
-					name:   part,
-					pkg:    p.pkg,
-					desc:   desc,
-					indent: (len(parts) - 1) * 2,
+					name:      part,
+					pkg:       p.pkg,
+					desc:      desc,
+					indent:    (len(parts) - 1) * 2,
+					params:    p.params,
+					types:     p.types,
+					resources: resources,
+					profiles:  p.profiles,
+					target:    p.target,
 				}
 				if child != nil {
 					// if node was already created as non-leaf the generator
@@ -76,42 +165,95 @@ func (p packageGenerator) Generate(f *codegen.File) {
 		if len(parts) > 1 {
 			return true
 		}
-
-		current.generator.GenerateHeader(f)
-
-		var visitChildren func(node *descNode)
-		visitChildren = func(node *descNode) {
-			for _, child := range node.children {
-				child.generator.GenerateHeader(f)
-				visitChildren(child)
-				child.generator.GenerateFields(f)
-			}
-		}
-		visitChildren(current)
-
-		current.generator.GenerateFields(f)
-		f.P()
-
+		fn(current)
 		return true
 	})
 }
 
-func (p packageGenerator) generateHeader(f *codegen.File) {
+func (p packageGenerator) generateHeader(f *codegen.File, resources resourceNames) {
+	p.generateImports(f, resources)
+	p.generatePb2Imports(f)
+	p.generateModuleImports(f)
+	if p.runtime != "" {
+		f.P("from .", p.runtime, " import ", strings.Join(p.runtimeImports(resources), ", "))
+		f.P()
+		p.generateExports(f, p.typeExports())
+	} else {
+		p.generateExports(f, append(p.helperExports(resources), p.typeExports()...))
+	}
+}
+
+// generateImports emits the banner of a module and the imports of the
+// standard library, Pydantic and typing.
+func (p packageGenerator) generateImports(f *codegen.File, resources resourceNames) {
 	f.P("####################################################################")
 	f.P("### This is an automatically generated file.        DO NOT EDIT  ###")
 	f.P("####################################################################")
 	f.P()
 	f.P("import datetime")
 	f.P("import json")
+	if len(resources) > 0 {
+		f.P("import re")
+	}
+	if boolParam(p.params, "wire_format") {
+		f.P("import struct")
+		f.P("import sys")
+	}
 	f.P()
-	f.P("from enum import StrEnum")
+	f.P("from enum import ", p.target.enumClass())
 	if p.params["pydantic_base_path"] != "" {
 		f.P("from ", p.params["pydantic_base_path"], " import BaseModel")
-		f.P("from pydantic import Field, field_serializer, model_validator, SerializationInfo")
+		f.P("from pydantic import Field, field_serializer, field_validator, model_validator, SerializationInfo")
+	} else {
+		f.P("from pydantic import BaseModel, Field, field_serializer, field_validator, model_validator, SerializationInfo")
+	}
+	if boolParam(p.params, "wire_format") {
+		p.target.generateTypingImports(f, "NamedTuple", "Optional", "Self")
 	} else {
-		f.P("from pydantic import BaseModel, Field, field_serializer, model_validator, SerializationInfo")
+		p.target.generateTypingImports(f, "Optional", "Self")
 	}
-	f.P("from typing import Optional, Self")
 	f.P("from uuid import UUID")
 	f.P()
 }
+
+// generateEnumBase emits the base class of generated enums, exposing the
+// metadata each enum records in __proto_values__.
+func (p packageGenerator) generateEnumBase(f *codegen.File) {
+	f.P("class ProtoEnum(", p.target.enumBase(), "):")
+	f.P(t(2), "__proto_closed__ = False")
+	f.P()
+	if !p.target.strEnum {
+		// as StrEnum does, so str() and format() agree on the value
+		f.P(t(2), "def __str__(self) -> str:")
+		f.P(t(4), "return self.value")
+		f.P()
+	}
+	f.P(t(2), "@classmethod")
+	f.P(t(2), "def from_number(cls, number: int) -> Self:")
+	f.P(t(4), "for name, value in cls.__proto_values__.items():")
+	f.P(t(6), `if value["number"] == number:`)
+	f.P(t(8), "return cls(name)")
+	f.P(t(4), `raise ValueError(f"{number!r} is not a valid {cls.__name__} number")`)
+	f.P()
+	f.P(t(2), "@property")
+	f.P(t(2), "def number(self) -> int:")
+	f.P(t(4), `return self.__proto_values__[self.value]["number"]`)
+	f.P()
+	f.P(t(2), "@property")
+	f.P(t(2), "def description(self) -> str:")
+	f.P(t(4), `return self.__proto_values__[self.value]["description"]`)
+	f.P()
+	f.P(t(2), "@property")
+	f.P(t(2), "def deprecated(self) -> bool:")
+	f.P(t(4), `return self.__proto_values__[self.value]["deprecated"]`)
+	f.P()
+	f.P(t(2), "@property")
+	f.P(t(2), "def options(self) -> dict:")
+	f.P(t(4), `return self.__proto_values__[self.value]["options"]`)
+	f.P()
+	f.P(t(2), "@classmethod")
+	f.P(t(2), "def is_closed(cls) -> bool:")
+	f.P(t(4), "return cls.__proto_closed__")
+	f.P()
+	f.P()
+}
@@ -0,0 +1,177 @@
+package plugin
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/cortea-ai/protoc-gen-pydantic/internal/codegen"
+	"github.com/cortea-ai/protoc-gen-pydantic/internal/protowalk"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// pb2Module returns the module protoc generates for a file with
+// --python_out, under the package given by the pb2_module parameter.
+func pb2Module(file protoreflect.FileDescriptor, prefix string) string {
+	module := strings.TrimSuffix(file.Path(), ".proto")
+	module = strings.ReplaceAll(strings.ReplaceAll(module, "-", "_"), "/", ".") + "_pb2"
+	if prefix == "" {
+		return module
+	}
+	return strings.TrimSuffix(prefix, ".") + "." + module
+}
+
+// pb2Alias returns the name a _pb2 module is imported as.
+func pb2Alias(file protoreflect.FileDescriptor) string {
+	return strings.ReplaceAll(strings.TrimSuffix(pb2Module(file, ""), "_pb2"), ".", "_") + "_pb2"
+}
+
+// pb2Class returns the _pb2 class of a message.
+func pb2Class(message protoreflect.MessageDescriptor) string {
+	return pb2Alias(message.ParentFile()) + "." + qualifiedTypeName(message)
+}
+
+// generatePb2Imports imports the _pb2 modules of the files declaring the
+// generated models.
+func (p packageGenerator) generatePb2Imports(f *codegen.File) {
+	prefix, ok := p.params["pb2_module"]
+	if !ok {
+		return
+	}
+	seen := make(map[string]struct{})
+	imports := make([]string, 0)
+	protowalk.WalkFiles(p.moduleFiles(), func(desc protoreflect.Descriptor) bool {
+		if message, ok := desc.(protoreflect.MessageDescriptor); ok && !IsWellKnownType(message) {
+			file := message.ParentFile()
+			if _, ok := seen[file.Path()]; !ok {
+				seen[file.Path()] = struct{}{}
+				imports = append(imports, "import "+pb2Module(file, prefix)+" as "+pb2Alias(file))
+			}
+		}
+		return true
+	})
+	sort.Strings(imports)
+	for _, line := range imports {
+		f.P(line)
+	}
+	if len(imports) > 0 {
+		f.P()
+	}
+}
+
+// generatePb2Helpers emits the conversions between models and _pb2 messages,
+// driven by the descriptors of the _pb2 classes.
+func generatePb2Helpers(f *codegen.File) {
+	f.P("def _to_proto_scalar(value, field):")
+	f.P(t(2), "if field.enum_type is not None:")
+	f.P(t(4), "return value.number")
+	f.P(t(2), "if field.type == field.TYPE_BYTES:")
+	f.P(t(4), `return value.encode("utf-8", "surrogateescape")`)
+	f.P(t(2), "if isinstance(value, UUID):")
+	f.P(t(4), "return str(value)")
+	f.P(t(2), "return value")
+	f.P()
+	f.P()
+	f.P("def _to_proto_key(key: str, field):")
+	f.P(t(2), "if field.type == field.TYPE_BOOL:")
+	f.P(t(4), `return key in ("true", "True")`)
+	f.P(t(2), "if field.type != field.TYPE_STRING:")
+	f.P(t(4), "return int(key)")
+	f.P(t(2), "return key")
+	f.P()
+	f.P()
+	f.P("def _to_proto_message(value, target):")
+	f.P(t(2), `if target.DESCRIPTOR.full_name == "google.protobuf.Timestamp":`)
+	f.P(t(4), "target.FromDatetime(value)")
+	f.P(t(2), `elif target.DESCRIPTOR.full_name == "google.protobuf.Duration":`)
+	f.P(t(4), "target.FromTimedelta(value)")
+	f.P(t(2), "else:")
+	f.P(t(4), "_to_proto(value, target)")
+	f.P(t(4), "target.SetInParent()")
+	f.P()
+	f.P()
+	f.P("def _to_proto(model: BaseModel, message):")
+	f.P(t(2), "for field in message.DESCRIPTOR.fields:")
+	f.P(t(4), "value = getattr(model, field.name, None)")
+	f.P(t(4), "if value is None:")
+	f.P(t(6), "continue")
+	f.P(t(4), "if field.message_type is not None and field.message_type.GetOptions().map_entry:")
+	f.P(t(6), "container = getattr(message, field.name)")
+	f.P(t(6), `key_field = field.message_type.fields_by_name["key"]`)
+	f.P(t(6), `value_field = field.message_type.fields_by_name["value"]`)
+	f.P(t(6), "for k, v in value.items():")
+	f.P(t(8), "k = _to_proto_key(k, key_field)")
+	f.P(t(8), "if value_field.message_type is not None:")
+	f.P(t(10), "_to_proto_message(v, container[k])")
+	f.P(t(8), "else:")
+	f.P(t(10), "container[k] = _to_proto_scalar(v, value_field)")
+	f.P(t(4), "elif field.label == field.LABEL_REPEATED:")
+	f.P(t(6), "container = getattr(message, field.name)")
+	f.P(t(6), "for v in value:")
+	f.P(t(8), "if field.message_type is not None:")
+	f.P(t(10), "_to_proto_message(v, container.add())")
+	f.P(t(8), "else:")
+	f.P(t(10), "container.append(_to_proto_scalar(v, field))")
+	f.P(t(4), "elif field.message_type is not None:")
+	f.P(t(6), "_to_proto_message(value, getattr(message, field.name))")
+	f.P(t(4), "else:")
+	f.P(t(6), "setattr(message, field.name, _to_proto_scalar(value, field))")
+	f.P(t(2), "return message")
+	f.P()
+	f.P()
+	f.P("def _from_proto_value(value, field):")
+	f.P(t(2), "if field.message_type is not None:")
+	f.P(t(4), `if field.message_type.full_name == "google.protobuf.Timestamp":`)
+	f.P(t(6), "return value.ToDatetime(tzinfo=datetime.timezone.utc)")
+	f.P(t(4), `if field.message_type.full_name == "google.protobuf.Duration":`)
+	f.P(t(6), "return value.ToTimedelta()")
+	f.P(t(4), "return _from_proto(value)")
+	f.P(t(2), "if field.enum_type is not None:")
+	f.P(t(4), "enum_value = field.enum_type.values_by_number.get(value)")
+	f.P(t(4), "return enum_value.name if enum_value is not None else value")
+	f.P(t(2), "if field.type == field.TYPE_BYTES:")
+	f.P(t(4), `return value.decode("utf-8", "surrogateescape")`)
+	f.P(t(2), "return value")
+	f.P()
+	f.P()
+	f.P("def _from_proto_key(key, field) -> str:")
+	f.P(t(2), "if field.type == field.TYPE_BOOL:")
+	f.P(t(4), `return "true" if key else "false"`)
+	f.P(t(2), "return str(key)")
+	f.P()
+	f.P()
+	f.P("def _from_proto(message) -> dict:")
+	f.P(t(2), "data = {}")
+	f.P(t(2), "for field in message.DESCRIPTOR.fields:")
+	f.P(t(4), "if field.has_presence and not message.HasField(field.name):")
+	f.P(t(6), "continue")
+	f.P(t(4), "value = getattr(message, field.name)")
+	f.P(t(4), "if field.message_type is not None and field.message_type.GetOptions().map_entry:")
+	f.P(t(6), `key_field = field.message_type.fields_by_name["key"]`)
+	f.P(t(6), `value_field = field.message_type.fields_by_name["value"]`)
+	f.P(t(6), "data[field.name] = {")
+	f.P(t(8), "_from_proto_key(k, key_field): _from_proto_value(v, value_field) for k, v in value.items()")
+	f.P(t(6), "}")
+	f.P(t(4), "elif field.label == field.LABEL_REPEATED:")
+	f.P(t(6), "data[field.name] = [_from_proto_value(v, field) for v in value]")
+	f.P(t(4), "else:")
+	f.P(t(6), "data[field.name] = _from_proto_value(value, field)")
+	f.P(t(2), "return data")
+	f.P()
+	f.P()
+}
+
+// generateProtoConversions emits the to_proto and from_proto methods of a
+// model.
+func (d descriptorGenerator) generateProtoConversions(f *codegen.File, message protoreflect.MessageDescriptor) {
+	if _, ok := d.params["pb2_module"]; !ok {
+		return
+	}
+	class := pb2Class(message)
+	f.P("")
+	f.P(t(d.indent+2), "def to_proto(self) -> ", class, ":")
+	f.P(t(d.indent+4), "return _to_proto(self, ", class, "())")
+	f.P("")
+	f.P(t(d.indent+2), "@classmethod")
+	f.P(t(d.indent+2), "def from_proto(cls, message: ", class, ") -> Self:")
+	f.P(t(d.indent+4), "return cls.model_validate(_from_proto(message))")
+}
@@ -0,0 +1,144 @@
+package plugin
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cortea-ai/protoc-gen-pydantic/internal/codegen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const profileParamPrefix = "profile."
+
+// Serialization transforms selectable by a profile.
+const (
+	transformMapsAsJSON        = "maps_as_json"
+	transformMapsAsRecords     = "maps_as_records"
+	transformEnumsAsInt        = "enums_as_int"
+	transformTimestampsAsEpoch = "timestamps_as_epoch"
+)
+
+// serializationProfile is a named set of transforms, applied when a model is
+// dumped with the profile name as serialization context.
+type serializationProfile struct {
+	name       string
+	transforms []string
+}
+
+type serializationProfiles []serializationProfile
+
+// defaultProfiles keeps the historical behaviour of encoding maps as JSON
+// strings for BigQuery when no profile is configured.
+var defaultProfiles = serializationProfiles{
+	{name: "bigquery", transforms: []string{transformMapsAsJSON}},
+}
+
+// parseProfiles reads profile.<name>=<transform>+<transform> parameters.
+func parseProfiles(params map[string]string) (serializationProfiles, error) {
+	var profiles serializationProfiles
+	for key, value := range params {
+		if !strings.HasPrefix(key, profileParamPrefix) {
+			continue
+		}
+		profile := serializationProfile{name: strings.TrimPrefix(key, profileParamPrefix)}
+		for _, transform := range strings.Split(value, "+") {
+			switch transform {
+			case transformMapsAsJSON, transformMapsAsRecords, transformEnumsAsInt, transformTimestampsAsEpoch:
+				profile.transforms = append(profile.transforms, transform)
+			default:
+				return nil, fmt.Errorf("profile %s: unknown transform %q", profile.name, transform)
+			}
+		}
+		profiles = append(profiles, profile)
+	}
+	if profiles == nil {
+		return defaultProfiles, nil
+	}
+	sort.Slice(profiles, func(i, j int) bool {
+		return profiles[i].name < profiles[j].name
+	})
+	return profiles, nil
+}
+
+func (p serializationProfiles) uses(transform string) bool {
+	for _, profile := range p {
+		for _, t := range profile.transforms {
+			if t == transform {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fieldKinds returns the kinds of a field that transforms of the profiles
+// apply to.
+func (p serializationProfiles) fieldKinds(field protoreflect.FieldDescriptor) []string {
+	kinds := make([]string, 0)
+	if field.IsMap() && (p.uses(transformMapsAsJSON) || p.uses(transformMapsAsRecords)) {
+		kinds = append(kinds, "map")
+	}
+	value := field
+	if field.IsMap() {
+		value = field.MapValue()
+	}
+	if value.Enum() != nil && p.uses(transformEnumsAsInt) {
+		kinds = append(kinds, "enum")
+	}
+	if value.Message() != nil && p.uses(transformTimestampsAsEpoch) {
+		if wkt, ok := WellKnownType(value.Message()); ok && (wkt == WellKnownTimestamp || wkt == WellKnownDuration) {
+			kinds = append(kinds, "timestamp")
+		}
+	}
+	return kinds
+}
+
+// generate emits the profiles and the serializer shared by every model.
+func (p serializationProfiles) generate(f *codegen.File, target pythonTarget) {
+	f.P("SERIALIZATION_PROFILES: ", target.generic("dict"), "[str, ", target.generic("frozenset"), "[str]] = {")
+	for _, profile := range p {
+		transforms := make([]string, 0, len(profile.transforms))
+		for _, transform := range profile.transforms {
+			transforms = append(transforms, strconv.Quote(transform))
+		}
+		f.P(t(2), strconv.Quote(profile.name), ": frozenset({", strings.Join(transforms, ", "), "}),")
+	}
+	f.P("}")
+	f.P()
+	f.P()
+	f.P("def _map_leaves(v, fn):")
+	f.P(t(2), "if v is None:")
+	f.P(t(4), "return None")
+	f.P(t(2), "if isinstance(v, list):")
+	f.P(t(4), "return [_map_leaves(x, fn) for x in v]")
+	f.P(t(2), "if isinstance(v, dict):")
+	f.P(t(4), "return {k: _map_leaves(x, fn) for k, x in v.items()}")
+	f.P(t(2), "return fn(v)")
+	f.P()
+	f.P()
+	f.P("def _epoch(v):")
+	f.P(t(2), "if isinstance(v, datetime.timedelta):")
+	f.P(t(4), "return v.total_seconds()")
+	f.P(t(2), "return v.timestamp()")
+	f.P()
+	f.P()
+	f.P("def _serialize_with_profile(v, handler, info: SerializationInfo, kinds: ", target.generic("tuple"), "[str, ...]):")
+	f.P(t(2), "profile = SERIALIZATION_PROFILES.get(info.context) if isinstance(info.context, str) else None")
+	f.P(t(2), "if not profile:")
+	f.P(t(4), "return handler(v)")
+	f.P(t(2), `if "enum" in kinds and "`, transformEnumsAsInt, `" in profile:`)
+	f.P(t(4), "v = _map_leaves(v, lambda x: x.number)")
+	f.P(t(2), `elif "timestamp" in kinds and "`, transformTimestampsAsEpoch, `" in profile:`)
+	f.P(t(4), "v = _map_leaves(v, _epoch)")
+	f.P(t(2), "else:")
+	f.P(t(4), "v = handler(v)")
+	f.P(t(2), `if "map" in kinds and "`, transformMapsAsJSON, `" in profile:`)
+	f.P(t(4), "return json.dumps(v, default=str)")
+	f.P(t(2), `if "map" in kinds and "`, transformMapsAsRecords, `" in profile:`)
+	f.P(t(4), `return [{"key": k, "value": x} for k, x in v.items()] if v is not None else None`)
+	f.P(t(2), "return v")
+	f.P()
+	f.P()
+}
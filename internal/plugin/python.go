@@ -0,0 +1,159 @@
+package plugin
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cortea-ai/protoc-gen-pydantic/internal/codegen"
+)
+
+// minPythonMinor is the oldest Python 3 release supported by Pydantic v2.
+const minPythonMinor = 8
+
+// pythonTarget holds the Python features the generated code may use.
+type pythonTarget struct {
+	// strEnum derives enums from enum.StrEnum (3.11) instead of str and Enum.
+	strEnum bool
+	// typingSelf imports Self from typing (3.11) instead of
+	// typing_extensions.
+	typingSelf bool
+	// unionSyntax writes unions as X | Y (3.10) instead of Union[X, Y].
+	unionSyntax bool
+	// noneUnion writes optional types as X | None (3.10) instead of
+	// Optional[X].
+	noneUnion bool
+	// builtinGenerics subscripts list, dict and tuple (3.9) instead of their
+	// typing aliases.
+	builtinGenerics bool
+}
+
+// parsePythonTarget returns the features of the python_version= parameter,
+// e.g. python_version=3.9. Without it the code targets Python 3.11. Optional
+// types are written as Optional[X] unless none_union is set, which needs
+// Python 3.10.
+func parsePythonTarget(params map[string]string) (pythonTarget, error) {
+	noneUnion := boolParam(params, "none_union")
+	version, ok := params["python_version"]
+	if !ok {
+		return pythonTarget{strEnum: true, typingSelf: true, unionSyntax: true, noneUnion: noneUnion, builtinGenerics: true}, nil
+	}
+	major, minor, found := strings.Cut(version, ".")
+	n, err := strconv.Atoi(minor)
+	if !found || major != "3" || err != nil {
+		return pythonTarget{}, fmt.Errorf("invalid python_version %q: expected 3.<minor>", version)
+	}
+	if n < minPythonMinor {
+		return pythonTarget{}, fmt.Errorf("python_version %s is not supported: Pydantic v2 requires Python 3.%d or later", version, minPythonMinor)
+	}
+	if noneUnion && n < 10 {
+		return pythonTarget{}, fmt.Errorf("none_union requires python_version 3.10 or later, got %s", version)
+	}
+	return pythonTarget{
+		strEnum:         n >= 11,
+		typingSelf:      n >= 11,
+		unionSyntax:     n >= 10,
+		noneUnion:       noneUnion,
+		builtinGenerics: n >= 9,
+	}, nil
+}
+
+// generic returns the name to subscript for the builtin list, dict, tuple,
+// frozenset or type.
+func (t pythonTarget) generic(name string) string {
+	if t.builtinGenerics {
+		return name
+	}
+	switch name {
+	case "frozenset":
+		return "FrozenSet"
+	default:
+		return strings.ToUpper(name[:1]) + name[1:]
+	}
+}
+
+// optional returns the annotation of a type that may be None.
+func (t pythonTarget) optional(annotation string) string {
+	if t.noneUnion {
+		return annotation + " | None"
+	}
+	return "Optional[" + annotation + "]"
+}
+
+// union returns the annotation of a union of types.
+func (t pythonTarget) union(annotations ...string) string {
+	if t.unionSyntax {
+		return strings.Join(annotations, " | ")
+	}
+	return "Union[" + strings.Join(annotations, ", ") + "]"
+}
+
+// reference returns the annotation of a field type.
+func (t pythonTarget) reference(typ Type, isUUID bool) string {
+	switch {
+	case typ.IsMap:
+		return t.generic("dict") + "[str, " + t.reference(*typ.Underlying, isUUID) + "]"
+	case typ.IsList:
+		return t.generic("list") + "[" + t.reference(*typ.Underlying, isUUID) + "]"
+	default:
+		return typ.Reference(isUUID)
+	}
+}
+
+// enumBase returns the base classes of ProtoEnum.
+func (t pythonTarget) enumBase() string {
+	if t.strEnum {
+		return "StrEnum"
+	}
+	return "str, Enum"
+}
+
+// enumClass returns the class every enum is a subclass of.
+func (t pythonTarget) enumClass() string {
+	if t.strEnum {
+		return "StrEnum"
+	}
+	return "Enum"
+}
+
+// generateTypingImports imports names from typing, leaving out those the
+// target writes with other syntax, adding the aliases of builtin generics
+// and importing what typing lacks from typing_extensions.
+func (t pythonTarget) generateTypingImports(f *codegen.File, names ...string) {
+	var typing, extensions []string
+	for _, name := range names {
+		switch {
+		case name == "Optional" && t.noneUnion, name == "Union" && t.unionSyntax:
+		case name == "Self" && !t.typingSelf:
+			extensions = append(extensions, name)
+		default:
+			typing = append(typing, name)
+		}
+	}
+	if !t.builtinGenerics {
+		typing = append(typing, "Dict", "FrozenSet", "List", "Tuple", "Type")
+	}
+	sort.Strings(typing)
+	if len(typing) > 0 {
+		f.P("from typing import ", strings.Join(typing, ", "))
+	}
+	if len(extensions) > 0 {
+		f.P("from typing_extensions import ", strings.Join(extensions, ", "))
+	}
+}
+
+// pythonAnnotation matches the annotations of the embedded Python helpers
+// that depend on the target.
+var pythonAnnotation = regexp.MustCompile(`\b(tuple|dict)\[|Optional\[(\w+)\]`)
+
+// rewrite adapts the annotations of embedded Python source to the target.
+func (t pythonTarget) rewrite(source string) string {
+	return pythonAnnotation.ReplaceAllStringFunc(source, func(match string) string {
+		if name, ok := strings.CutSuffix(match, "["); ok && !strings.HasPrefix(match, "Optional") {
+			return t.generic(name) + "["
+		}
+		return t.optional(strings.TrimSuffix(strings.TrimPrefix(match, "Optional["), "]"))
+	})
+}
@@ -0,0 +1,131 @@
+package plugin
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+// pydanticStandIn is imported as pydantic where Pydantic is not installed.
+// It executes the class bodies of the generated modules, so names that do
+// not resolve still fail, and builds and compares models without validating
+// them.
+const pydanticStandIn = `
+_MISSING = object()
+
+
+class _FieldInfo:
+    def __init__(self, default=_MISSING, default_factory=None, **kwargs):
+        self.default = default
+        self.default_factory = default_factory
+
+    def is_required(self):
+        return self.default is _MISSING and self.default_factory is None
+
+
+def Field(default=_MISSING, default_factory=None, **kwargs):
+    return _FieldInfo(default, default_factory)
+
+
+class BaseModel:
+    model_fields = {}
+
+    def __init_subclass__(cls, **kwargs):
+        cls.model_fields = {
+            name: cls.__dict__.get(name, _FieldInfo()) for name in cls.__dict__.get("__annotations__", {})
+        }
+
+    def __init__(self, **data):
+        for name, info in type(self).model_fields.items():
+            if name in data:
+                v = data[name]
+            elif info.default_factory is not None:
+                v = info.default_factory()
+            elif info.default is not _MISSING:
+                v = info.default
+            else:
+                raise ValueError(f"{type(self).__name__}.{name} is required")
+            object.__setattr__(self, name, v)
+
+    @classmethod
+    def model_validate(cls, data):
+        return cls(**data)
+
+    def __eq__(self, other):
+        return type(self) is type(other) and all(getattr(self, k) == getattr(other, k) for k in self.model_fields)
+
+    def __repr__(self):
+        return f"{type(self).__name__}({', '.join(f'{k}={getattr(self, k)!r}' for k in self.model_fields)})"
+
+
+def _decorator(*args, **kwargs):
+    return lambda f: f
+
+
+field_serializer = field_validator = model_validator = _decorator
+SerializationInfo = object
+`
+
+// generateRequest generates the files of a request in the text format.
+func generateRequest(t *testing.T, request string) *pluginpb.CodeGeneratorResponse {
+	t.Helper()
+	var req pluginpb.CodeGeneratorRequest
+	if err := prototext.Unmarshal([]byte(request), &req); err != nil {
+		t.Fatalf("unmarshal request: %v", err)
+	}
+	res, err := Generate(&req)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if res.GetError() != "" {
+		t.Fatalf("Generate: %s", res.GetError())
+	}
+	return res
+}
+
+// runGenerated writes the generated files and extra files, keyed by path, to
+// a temporary directory and runs a Python script with them on the path. It
+// returns the standard output of the script, and is skipped without python3.
+func runGenerated(t *testing.T, res *pluginpb.CodeGeneratorResponse, extra map[string]string, script string, args ...string) string {
+	t.Helper()
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 not found")
+	}
+	dir := t.TempDir()
+	files := make(map[string]string, len(extra)+len(res.GetFile()))
+	for name, content := range extra {
+		files[name] = content
+	}
+	for _, file := range res.GetFile() {
+		files[file.GetName()] = file.GetContent()
+	}
+	if err := exec.Command(python, "-c", "import pydantic").Run(); err != nil {
+		files["pydantic/__init__.py"] = pydanticStandIn
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command(python, append([]string{"-c", script}, args...)...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "PYTHONPATH="+dir+string(os.PathListSeparator)+os.Getenv("PYTHONPATH"))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("run generated modules: %v\n%s", err, stderr.String())
+	}
+	return string(out)
+}
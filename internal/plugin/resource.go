@@ -0,0 +1,190 @@
+package plugin
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cortea-ai/protoc-gen-pydantic/internal/codegen"
+	"github.com/cortea-ai/protoc-gen-pydantic/internal/protowalk"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+var resourceVariable = regexp.MustCompile(`\{([a-z_0-9]+)\}`)
+
+// resourceName is a google.api.resource generated as a resource name class.
+type resourceName struct {
+	resource  *annotations.ResourceDescriptor
+	className string
+}
+
+type resourceNames []resourceName
+
+// packageResources collects the resources declared by the messages of a
+// package and by the google.api.resource_definition options of its files.
+func packageResources(pkg protoreflect.FullName, files []protoreflect.FileDescriptor) resourceNames {
+	var names resourceNames
+	add := func(resource *annotations.ResourceDescriptor) {
+		if resource.GetType() == "" || len(resource.GetPattern()) == 0 || names.find(resource.GetType()) != nil {
+			return
+		}
+		typeName := resource.GetType()[strings.LastIndex(resource.GetType(), "/")+1:]
+		names = append(names, resourceName{resource: resource, className: typeName + "Name"})
+	}
+	for _, file := range files {
+		for _, resource := range proto.GetExtension(file.Options(), annotations.E_ResourceDefinition).([]*annotations.ResourceDescriptor) {
+			add(resource)
+		}
+	}
+	protowalk.WalkFiles(files, func(desc protoreflect.Descriptor) bool {
+		if message, ok := desc.(protoreflect.MessageDescriptor); ok && message.ParentFile().Package() == pkg {
+			if resource := messageResource(message); resource != nil {
+				add(resource)
+			}
+		}
+		return true
+	})
+	return names
+}
+
+func (r resourceNames) find(resourceType string) *resourceName {
+	for i := range r {
+		if r[i].resource.GetType() == resourceType {
+			return &r[i]
+		}
+	}
+	return nil
+}
+
+func messageResource(message protoreflect.MessageDescriptor) *annotations.ResourceDescriptor {
+	if resource, ok := proto.GetExtension(message.Options(), annotations.E_Resource).(*annotations.ResourceDescriptor); ok && resource != nil {
+		return resource
+	}
+	return nil
+}
+
+// fieldResource returns the resource whose name a field holds, either as the
+// name field of a resource message or through google.api.resource_reference.
+func (r resourceNames) fieldResource(field protoreflect.FieldDescriptor) *resourceName {
+	if field.Kind() != protoreflect.StringKind || field.IsMap() {
+		return nil
+	}
+	if message, ok := field.Parent().(protoreflect.MessageDescriptor); ok {
+		if resource := messageResource(message); resource != nil {
+			nameField := resource.GetNameField()
+			if nameField == "" {
+				nameField = "name"
+			}
+			if string(field.Name()) == nameField {
+				return r.find(resource.GetType())
+			}
+		}
+	}
+	if reference, ok := proto.GetExtension(field.Options(), annotations.E_ResourceReference).(*annotations.ResourceReference); ok && reference != nil {
+		return r.find(reference.GetType())
+	}
+	return nil
+}
+
+// generate emits the resource name classes of a package.
+func (r resourceNames) generate(f *codegen.File, target pythonTarget) {
+	if len(r) == 0 {
+		return
+	}
+	f.P("def _resource_pattern(pattern: str) -> re.Pattern:")
+	f.P(t(2), `return re.compile(re.sub(r"\\\{(\w+)\\\}", r"(?P<\1>[^/]+)", re.escape(pattern)))`)
+	f.P()
+	f.P()
+	f.P("class ResourceName(BaseModel):")
+	f.P(t(2), `model_config = {"frozen": True}`)
+	f.P()
+	f.P(t(2), "@classmethod")
+	f.P(t(2), "def parse(cls, name: str) -> Self:")
+	f.P(t(4), "for pattern in cls.__patterns__:")
+	f.P(t(6), "match = _resource_pattern(pattern).fullmatch(name)")
+	f.P(t(6), "if match:")
+	f.P(t(8), "return cls(**match.groupdict())")
+	f.P(t(4), `raise ValueError(f"{name!r} is not a valid {cls.__resource_type__} name")`)
+	f.P()
+	f.P(t(2), "@classmethod")
+	f.P(t(2), "def matches(cls, name: str) -> bool:")
+	f.P(t(4), "return any(_resource_pattern(pattern).fullmatch(name) for pattern in cls.__patterns__)")
+	f.P()
+	f.P(t(2), "def format(self) -> str:")
+	f.P(t(4), "values = self.model_dump(exclude_none=True)")
+	f.P(t(4), "for pattern in self.__patterns__:")
+	f.P(t(6), "if set(_resource_pattern(pattern).groupindex) == set(values):")
+	f.P(t(8), "return pattern.format(**values)")
+	f.P(t(4), `raise ValueError(f"no {self.__resource_type__} pattern matches {values}")`)
+	f.P()
+	f.P(t(2), "def __str__(self) -> str:")
+	f.P(t(4), "return self.format()")
+	f.P()
+	f.P()
+	for _, name := range r {
+		name.generate(f, target)
+	}
+}
+
+// regexp returns a regular expression matching the names of any pattern of
+// the resource, as _resource_pattern does in Python.
+func (n resourceName) regexp() string {
+	alternatives := make([]string, 0, len(n.resource.GetPattern()))
+	for _, pattern := range n.resource.GetPattern() {
+		var sb strings.Builder
+		last := 0
+		for _, loc := range resourceVariable.FindAllStringIndex(pattern, -1) {
+			sb.WriteString(regexp.QuoteMeta(pattern[last:loc[0]]))
+			sb.WriteString("[^/]+")
+			last = loc[1]
+		}
+		sb.WriteString(regexp.QuoteMeta(pattern[last:]))
+		alternatives = append(alternatives, sb.String())
+	}
+	return "(?:" + strings.Join(alternatives, "|") + ")"
+}
+
+// variables returns the variables of the patterns of the resource, and
+// whether every pattern has them.
+func (n resourceName) variables() ([]string, map[string]bool) {
+	variables := make([]string, 0)
+	counts := make(map[string]int)
+	for _, pattern := range n.resource.GetPattern() {
+		for _, match := range resourceVariable.FindAllStringSubmatch(pattern, -1) {
+			if counts[match[1]] == 0 {
+				variables = append(variables, match[1])
+			}
+			counts[match[1]]++
+		}
+	}
+	required := make(map[string]bool, len(variables))
+	for _, variable := range variables {
+		required[variable] = counts[variable] == len(n.resource.GetPattern())
+	}
+	return variables, required
+}
+
+func (n resourceName) generate(f *codegen.File, target pythonTarget) {
+	patterns := make([]string, 0, len(n.resource.GetPattern()))
+	for _, pattern := range n.resource.GetPattern() {
+		patterns = append(patterns, strconv.Quote(pattern)+",")
+	}
+	f.P("class ", n.className, "(ResourceName):")
+	f.P(t(2), `"""Resource name of `, n.resource.GetType(), `."""`)
+	f.P(t(2), "__resource_type__ = ", strconv.Quote(n.resource.GetType()))
+	f.P(t(2), "__patterns__ = (", strings.Join(patterns, " "), ")")
+	f.P()
+	variables, required := n.variables()
+	for _, variable := range variables {
+		// variables missing from some patterns are only set by the others
+		if required[variable] {
+			f.P(t(2), variable, ": str = Field()")
+		} else {
+			f.P(t(2), variable, ": ", target.optional("str"), " = Field(default=None)")
+		}
+	}
+	f.P()
+	f.P()
+}
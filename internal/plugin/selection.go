@@ -0,0 +1,181 @@
+package plugin
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/cortea-ai/protoc-gen-pydantic/internal/protowalk"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// selection holds the full names of the top-level messages and enums to
+// generate; nil selects every type.
+type selection map[protoreflect.FullName]struct{}
+
+// has reports whether a type, or the top-level type enclosing it, is
+// selected.
+func (s selection) has(desc protoreflect.Descriptor) bool {
+	if s == nil {
+		return true
+	}
+	_, ok := s[topLevelDescriptor(desc).FullName()]
+	return ok
+}
+
+// hasAny reports whether any type declared by the files is selected.
+func (s selection) hasAny(files []protoreflect.FileDescriptor) bool {
+	if s == nil {
+		return true
+	}
+	for _, file := range files {
+		for i := 0; i < file.Messages().Len(); i++ {
+			if s.has(file.Messages().Get(i)) {
+				return true
+			}
+		}
+		for i := 0; i < file.Enums().Len(); i++ {
+			if s.has(file.Enums().Get(i)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// close adds the types the selected types depend on, and the top-level types
+// enclosing them, until no type is added.
+func (s selection) close(pending []protoreflect.Descriptor) {
+	for len(pending) > 0 {
+		walked := pending
+		pending = nil
+		protowalk.WalkDescriptors(walked, func(desc protoreflect.Descriptor) bool {
+			switch desc.(type) {
+			case protoreflect.MessageDescriptor, protoreflect.EnumDescriptor:
+				top := topLevelDescriptor(desc)
+				if _, ok := s[top.FullName()]; !ok {
+					s[top.FullName()] = struct{}{}
+					pending = append(pending, top)
+				}
+			}
+			return true
+		})
+	}
+}
+
+// parseSelection selects the top-level types of the files matching the
+// include= and exclude= glob patterns, with their dependencies. Patterns
+// match package names, file paths and type full names with path.Match.
+// With roots=, it selects the types reachable from the roots instead.
+func parseSelection(registry *protoregistry.Files, packaged map[protoreflect.FullName][]protoreflect.FileDescriptor, params map[string]string) (selection, error) {
+	include := listParam(params, "include")
+	exclude := listParam(params, "exclude")
+	if roots := listParam(params, "roots"); len(roots) > 0 {
+		if len(include) > 0 || len(exclude) > 0 {
+			return nil, fmt.Errorf("roots cannot be combined with include or exclude")
+		}
+		return rootSelection(registry, roots)
+	}
+	if len(include) == 0 && len(exclude) == 0 {
+		return nil, nil
+	}
+	for _, pattern := range append(append([]string{}, include...), exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	s := make(selection)
+	selected := make([]protoreflect.Descriptor, 0)
+	add := func(desc protoreflect.Descriptor) {
+		if (len(include) == 0 || matchesAny(include, desc)) && !matchesAny(exclude, desc) {
+			s[desc.FullName()] = struct{}{}
+			selected = append(selected, desc)
+		}
+	}
+	for _, pkg := range sortedPackages(packaged) {
+		for _, file := range packaged[pkg] {
+			for i := 0; i < file.Messages().Len(); i++ {
+				add(file.Messages().Get(i))
+			}
+			for i := 0; i < file.Enums().Len(); i++ {
+				add(file.Enums().Get(i))
+			}
+		}
+	}
+	s.close(selected)
+	return s, nil
+}
+
+// rootSelection selects the types reachable from messages, enums and
+// services given by full name.
+func rootSelection(registry *protoregistry.Files, roots []string) (selection, error) {
+	descs := make([]protoreflect.Descriptor, 0, len(roots))
+	for _, root := range roots {
+		desc, err := registry.FindDescriptorByName(protoreflect.FullName(root))
+		if err != nil {
+			return nil, fmt.Errorf("find root %s: %w", root, err)
+		}
+		switch desc.(type) {
+		case protoreflect.MessageDescriptor, protoreflect.EnumDescriptor, protoreflect.ServiceDescriptor:
+			descs = append(descs, desc)
+		default:
+			return nil, fmt.Errorf("root %s is not a message, enum or service", root)
+		}
+	}
+	s := make(selection)
+	s.close(descs)
+	return s, nil
+}
+
+// pruned returns the number of messages and enums declared by the files
+// that are not selected.
+func (s selection) pruned(files []protoreflect.FileDescriptor) (pruned, total int) {
+	paths := make(map[string]struct{}, len(files))
+	for _, file := range files {
+		paths[file.Path()] = struct{}{}
+	}
+	protowalk.WalkFiles(files, func(desc protoreflect.Descriptor) bool {
+		switch desc := desc.(type) {
+		case protoreflect.MessageDescriptor:
+			if desc.IsMapEntry() {
+				return true
+			}
+		case protoreflect.EnumDescriptor:
+		default:
+			return true
+		}
+		if _, ok := paths[desc.ParentFile().Path()]; !ok {
+			return true
+		}
+		total++
+		if !s.has(desc) {
+			pruned++
+		}
+		return true
+	})
+	return pruned, total
+}
+
+// matchesAny reports whether a pattern matches the package, the file path or
+// the full name of a type.
+func matchesAny(patterns []string, desc protoreflect.Descriptor) bool {
+	names := []string{string(desc.ParentFile().Package()), desc.ParentFile().Path(), string(desc.FullName())}
+	for _, pattern := range patterns {
+		for _, name := range names {
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// listParam returns the comma separated values of a parameter that may be
+// repeated.
+func listParam(params map[string]string, name string) []string {
+	if params[name] == "" {
+		return nil
+	}
+	return strings.Split(params[name], ",")
+}
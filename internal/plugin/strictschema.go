@@ -0,0 +1,172 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cortea-ai/protoc-gen-pydantic/internal/codegen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// strictSchema builds the JSON schema of a message in the subset accepted by
+// the strict structured output and tool calling modes of LLM APIs: every
+// property is required, optional fields are nullable instead, objects reject
+// additional properties and only type, enum, format, items, anyOf, $ref and
+// description keywords are used.
+type strictSchema struct {
+	pkg    protoreflect.FullName
+	params map[string]string
+	defs   jsonObject
+	stack  []protoreflect.FullName
+}
+
+func newStrictSchema(pkg protoreflect.FullName, params map[string]string) *strictSchema {
+	return &strictSchema{pkg: pkg, params: params, defs: jsonObject{}}
+}
+
+// generate returns the strict schema of a message as JSON.
+func (s *strictSchema) generate(message protoreflect.MessageDescriptor) (string, error) {
+	schema, err := s.object(message)
+	if err != nil {
+		return "", err
+	}
+	if len(s.defs) > 0 {
+		schema = schema.set("$defs", s.defs)
+	}
+	content, err := json.Marshal(schema)
+	if err != nil {
+		return "", fmt.Errorf("marshal strict JSON schema of %s: %w", message.FullName(), err)
+	}
+	return string(content), nil
+}
+
+func (s *strictSchema) object(message protoreflect.MessageDescriptor) (jsonObject, error) {
+	for _, name := range s.stack {
+		if name == message.FullName() {
+			return nil, fmt.Errorf("%s is recursive", message.FullName())
+		}
+	}
+	s.stack = append(s.stack, message.FullName())
+	defer func() { s.stack = s.stack[:len(s.stack)-1] }()
+
+	schema := jsonObject{{"type", "object"}}
+	if text := (commentGenerator{descriptor: message}).text(); text != "" {
+		schema = schema.set("description", text)
+	}
+	properties := jsonObject{}
+	required := make([]string, 0, message.Fields().Len())
+	for i := 0; i < message.Fields().Len(); i++ {
+		field := message.Fields().Get(i)
+		if field.IsMap() {
+			return nil, fmt.Errorf("map field %s has arbitrary keys", field.FullName())
+		}
+		if isOneOfField(field) {
+			return nil, fmt.Errorf("oneof field %s is a union", field.FullName())
+		}
+		property, err := s.field(newPydanticField(s.pkg, field, s.params))
+		if err != nil {
+			return nil, err
+		}
+		properties = properties.set(string(field.Name()), property)
+		required = append(required, string(field.Name()))
+	}
+	return schema.
+		set("properties", properties).
+		set("required", required).
+		set("additionalProperties", false), nil
+}
+
+func (s *strictSchema) field(pf pydanticField) (jsonObject, error) {
+	schema, err := s.value(pf.field, pf.isUUID)
+	if err != nil {
+		return nil, err
+	}
+	if pf.field.IsList() {
+		schema = jsonObject{{"type", "array"}, {"items", schema}}
+	}
+	if pf.isOptional {
+		schema = jsonObject{{"anyOf", []jsonObject{schema, {{"type", "null"}}}}}
+	}
+	if text := (commentGenerator{descriptor: pf.field}).text(); text != "" {
+		schema = schema.set("description", text)
+	}
+	return schema, nil
+}
+
+func (s *strictSchema) value(field protoreflect.FieldDescriptor, isUUID bool) (jsonObject, error) {
+	switch field.Kind() {
+	case protoreflect.StringKind, protoreflect.BytesKind:
+		if isUUID {
+			return jsonObject{{"type", "string"}, {"format", "uuid"}}, nil
+		}
+		return jsonObject{{"type", "string"}}, nil
+	case protoreflect.BoolKind:
+		return jsonObject{{"type", "boolean"}}, nil
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return jsonObject{{"type", "number"}}, nil
+	case protoreflect.EnumKind:
+		dropUnspecified := boolParam(s.params, "drop_unspecified")
+		names := make([]string, 0)
+		rangeEnumValues(field.Enum(), func(value protoreflect.EnumValueDescriptor, _ bool) {
+			if primaryEnumValue(value, dropUnspecified) != value || (dropUnspecified && isUnspecifiedValue(value)) {
+				return
+			}
+			names = append(names, string(value.Name()))
+		})
+		return jsonObject{{"type", "string"}, {"enum", names}}, nil
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		if wkt, ok := WellKnownType(field.Message()); ok {
+			switch wkt {
+			case WellKnownTimestamp:
+				return jsonObject{{"type", "string"}, {"format", "date-time"}}, nil
+			case WellKnownDuration:
+				return jsonObject{{"type", "string"}, {"format", "duration"}}, nil
+			}
+		}
+		message := field.Message()
+		name := strings.ReplaceAll(string(message.FullName()), ".", "_")
+		for _, def := range s.defs {
+			if def.key == name {
+				return jsonObject{{"$ref", "#/$defs/" + name}}, nil
+			}
+		}
+		object, err := s.object(message)
+		if err != nil {
+			return nil, err
+		}
+		s.defs = s.defs.set(name, object)
+		return jsonObject{{"$ref", "#/$defs/" + name}}, nil
+	default:
+		return jsonObject{{"type", "integer"}}, nil
+	}
+}
+
+// generateStrictSchemaHelper emits the accessor of the strict schemas
+// recorded on the models.
+func generateStrictSchemaHelper(f *codegen.File, target pythonTarget) {
+	f.P("def strict_json_schema(model: ", target.generic("type"), "[BaseModel]) -> dict:")
+	f.P(t(2), `"""Returns the strict JSON schema of a model, for LLM structured outputs and tool calling."""`)
+	f.P(t(2), "if model.__strict_json_schema__ is None:")
+	f.P(t(4), `raise TypeError(f"{model.__name__} has no strict JSON schema: {model.__strict_json_schema_error__}")`)
+	f.P(t(2), "return json.loads(model.__strict_json_schema__)")
+	f.P()
+	f.P()
+}
+
+// generateStrictSchema records the strict schema of a message on its model,
+// or why it cannot have one.
+func (d descriptorGenerator) generateStrictSchema(f *codegen.File, message protoreflect.MessageDescriptor) {
+	if !boolParam(d.params, "strict_schema") {
+		return
+	}
+	schema, err := newStrictSchema(d.pkg, d.params).generate(message)
+	f.P("")
+	if err != nil {
+		f.P(t(d.indent+2), "__strict_json_schema__ = None")
+		f.P(t(d.indent+2), "__strict_json_schema_error__ = ", strconv.Quote(err.Error()))
+		return
+	}
+	f.P(t(d.indent+2), "__strict_json_schema__ = ", strconv.Quote(schema))
+}
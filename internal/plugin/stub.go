@@ -0,0 +1,273 @@
+package plugin
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/cortea-ai/protoc-gen-pydantic/internal/codegen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// GenerateStub emits the .pyi stub of the module, declaring the models with
+// explicit keyword-only __init__ signatures for type checkers.
+func (p packageGenerator) GenerateStub(f *codegen.File) {
+	resources := packageResources(p.pkg, p.files)
+	p.generateStubImports(f)
+	p.generatePb2Imports(f)
+	p.generateModuleImports(f)
+	if p.runtime != "" {
+		f.P("from .", p.runtime, " import ProtoEnum")
+		f.P()
+		p.generateExports(f, p.typeExports())
+	} else {
+		p.generateExports(f, append(p.helperExports(resources), p.typeExports()...))
+		p.generateStubHelpers(f, resources)
+	}
+
+	p.rangeDescriptorTrees(resources, func(node *descNode) {
+		var visit func(node *descNode)
+		visit = func(node *descNode) {
+			node.generator.generateStubHeader(f)
+			for _, child := range node.children {
+				visit(child)
+			}
+			node.generator.generateStubFields(f)
+			if node.generator.indent > 0 {
+				node.generator.generateStubVariants(f)
+			}
+		}
+		visit(node)
+		f.P()
+		node.generator.generateStubVariants(f)
+	})
+}
+
+// GenerateRuntimeStub emits the .pyi stub of the runtime module of a package
+// with the file layout.
+func (p packageGenerator) GenerateRuntimeStub(f *codegen.File) {
+	resources := packageResources(p.pkg, p.files)
+	p.generateStubImports(f)
+	p.generateExports(f, p.helperExports(resources))
+	p.generateStubHelpers(f, resources)
+}
+
+func (p packageGenerator) generateStubImports(f *codegen.File) {
+	f.P("####################################################################")
+	f.P("### This is an automatically generated file.        DO NOT EDIT  ###")
+	f.P("####################################################################")
+	f.P()
+	f.P("import datetime")
+	f.P()
+	f.P("from enum import ", p.target.enumClass())
+	if p.params["pydantic_base_path"] != "" {
+		f.P("from ", p.params["pydantic_base_path"], " import BaseModel")
+	} else {
+		f.P("from pydantic import BaseModel")
+	}
+	p.target.generateTypingImports(f, "Any", "ClassVar", "Literal", "Optional", "Self", "Union")
+	f.P("from uuid import UUID")
+	f.P()
+}
+
+// generateStubHelpers declares the helpers the module defines besides the
+// models.
+func (p packageGenerator) generateStubHelpers(f *codegen.File, resources resourceNames) {
+	dict := p.target.generic("dict")
+	f.P("class ProtoEnum(", p.target.enumBase(), "):")
+	f.P(t(2), "__proto_closed__: ClassVar[bool]")
+	f.P(t(2), "__proto_values__: ClassVar[", dict, "[str, ", dict, "[str, Any]]]")
+	f.P(t(2), "@classmethod")
+	f.P(t(2), "def from_number(cls, number: int) -> Self: ...")
+	f.P(t(2), "@property")
+	f.P(t(2), "def number(self) -> int: ...")
+	f.P(t(2), "@property")
+	f.P(t(2), "def description(self) -> str: ...")
+	f.P(t(2), "@property")
+	f.P(t(2), "def deprecated(self) -> bool: ...")
+	f.P(t(2), "@property")
+	f.P(t(2), "def options(self) -> dict: ...")
+	f.P(t(2), "@classmethod")
+	f.P(t(2), "def is_closed(cls) -> bool: ...")
+	f.P()
+	f.P()
+	if len(resources) > 0 {
+		f.P("class ResourceName(BaseModel):")
+		f.P(t(2), "__resource_type__: ClassVar[str]")
+		f.P(t(2), "__patterns__: ClassVar[", p.target.generic("tuple"), "[str, ...]]")
+		f.P(t(2), "@classmethod")
+		f.P(t(2), "def parse(cls, name: str) -> Self: ...")
+		f.P(t(2), "@classmethod")
+		f.P(t(2), "def matches(cls, name: str) -> bool: ...")
+		f.P(t(2), "def format(self) -> str: ...")
+		f.P()
+		f.P()
+		for _, resource := range resources {
+			variables, required := resource.variables()
+			params := make([]string, 0, len(variables))
+			f.P("class ", resource.className, "(ResourceName):")
+			for _, variable := range variables {
+				if required[variable] {
+					f.P(t(2), variable, ": str")
+					params = append(params, variable+": str")
+				} else {
+					f.P(t(2), variable, ": ", p.target.optional("str"))
+					params = append(params, variable+": "+p.target.optional("str")+" = ...")
+				}
+			}
+			f.P(t(2), "def __init__(self, *, ", strings.Join(params, ", "), ") -> None: ...")
+			f.P()
+			f.P()
+		}
+	}
+	f.P("SERIALIZATION_PROFILES: ", dict, "[str, ", p.target.generic("frozenset"), "[str]]")
+	f.P()
+	if boolParam(p.params, "strict_schema") {
+		f.P("def strict_json_schema(model: ", p.target.generic("type"), "[BaseModel]) -> dict: ...")
+		f.P()
+	}
+	if boolParam(p.params, "descriptors") {
+		f.P("FILE_DESCRIPTORS: ", dict, "[str, bytes]")
+		f.P()
+		f.P("def add_file_descriptors(pool: Any = None) -> Any: ...")
+		f.P("def proto_descriptor(cls: type, pool: Any = None) -> Any: ...")
+		f.P()
+	}
+	f.P()
+}
+
+func (d descriptorGenerator) generateStubHeader(f *codegen.File) {
+	switch d.desc.(type) {
+	case protoreflect.EnumDescriptor:
+		f.P(t(d.indent), "class ", d.name, "(ProtoEnum):")
+	case protoreflect.MessageDescriptor:
+		f.P(t(d.indent), "class ", d.name, "(BaseModel):")
+	}
+}
+
+func (d descriptorGenerator) generateStubFields(f *codegen.File) {
+	switch desc := d.desc.(type) {
+	case protoreflect.EnumDescriptor:
+		dropUnspecified := boolParam(d.params, "drop_unspecified")
+		members := 0
+		rangeEnumValues(desc, func(value protoreflect.EnumValueDescriptor, _ bool) {
+			if dropUnspecified && isUnspecifiedValue(value) {
+				return
+			}
+			if primary := primaryEnumValue(value, dropUnspecified); primary != value {
+				f.P(t(d.indent+2), string(value.Name()), " = ", string(primary.Name()))
+			} else {
+				f.P(t(d.indent+2), string(value.Name()), " = ", strconv.Quote(string(value.Name())))
+			}
+			members++
+		})
+		if members == 0 {
+			f.P(t(d.indent+2), "...")
+		}
+		d.generateStubAccessors(f)
+	case protoreflect.MessageDescriptor:
+		if IsWellKnownType(desc) {
+			f.P(t(d.indent+2), "...")
+			return
+		}
+		fields := d.pydanticFields(desc)
+		d.generateStubModel(f, fields)
+		if boolParam(d.params, "strict_schema") {
+			f.P(t(d.indent+2), "__strict_json_schema__: ClassVar[", d.target.optional("str"), "]")
+		}
+		if _, ok := d.params["pb2_module"]; ok {
+			class := pb2Class(desc)
+			f.P(t(d.indent+2), "def to_proto(self) -> ", class, ": ...")
+			f.P(t(d.indent+2), "@classmethod")
+			f.P(t(d.indent+2), "def from_proto(cls, message: ", class, ") -> Self: ...")
+		}
+		if boolParam(d.params, "wire_format") {
+			f.P(t(d.indent+2), "def to_bytes(self) -> bytes: ...")
+			f.P(t(d.indent+2), "@classmethod")
+			f.P(t(d.indent+2), "def from_bytes(cls, data: bytes) -> Self: ...")
+		}
+		d.generateStubAccessors(f)
+	}
+}
+
+// generateStubModel declares the fields of a model and its __init__.
+func (d descriptorGenerator) generateStubModel(f *codegen.File, fields []pydanticField) {
+	params := make([]string, 0, len(fields))
+	for _, pf := range fields {
+		f.P(t(d.indent+2), string(pf.field.Name()), ": ", pf.annotation(d.target))
+		param := string(pf.field.Name()) + ": " + pf.parameterType(d.target, boolParam(d.params, "drop_unspecified"))
+		if pf.defaultValue != "" || pf.defaultFactory != "" {
+			param += " = ..."
+		}
+		params = append(params, param)
+	}
+	if len(params) == 0 {
+		f.P(t(d.indent+2), "def __init__(self) -> None: ...")
+		return
+	}
+	f.P(t(d.indent+2), "def __init__(self, *, ", strings.Join(params, ", "), ") -> None: ...")
+}
+
+func (d descriptorGenerator) generateStubAccessors(f *codegen.File) {
+	if !boolParam(d.params, "descriptors") {
+		return
+	}
+	f.P(t(d.indent+2), "__proto_full_name__: ClassVar[str]")
+	f.P(t(d.indent+2), "__proto_file__: ClassVar[str]")
+	if _, ok := d.desc.(protoreflect.MessageDescriptor); ok {
+		f.P(t(d.indent+2), "__proto_field_numbers__: ClassVar[", d.target.generic("dict"), "[str, int]]")
+	}
+}
+
+// generateStubVariants declares the Create and Update input models of a
+// message, as GenerateVariants generates them.
+func (d descriptorGenerator) generateStubVariants(f *codegen.File) {
+	message, ok := d.desc.(protoreflect.MessageDescriptor)
+	if !ok || !boolParam(d.params, "input_variants") || !hasFieldBehaviors(message) {
+		return
+	}
+	for _, variant := range []struct {
+		name   string
+		fields []pydanticField
+	}{
+		{"Create", createVariantFields(d.pydanticFields(message))},
+		{"Update", updateVariantFields(d.pydanticFields(message))},
+	} {
+		f.P(t(d.indent), "class ", d.name, variant.name, "(BaseModel):")
+		d.generateStubModel(f, d.variantFields(variant.fields))
+		f.P()
+		f.P()
+	}
+}
+
+// parameterType returns the type of the field as an __init__ parameter,
+// where enums also accept the names of their values, as the models do.
+func (pf pydanticField) parameterType(target pythonTarget, dropUnspecified bool) string {
+	value := pf.fieldType
+	if value.Underlying != nil {
+		value = *value.Underlying
+	}
+	reference := value.Reference(pf.isUUID)
+	valueField := pf.field
+	if pf.field.IsMap() {
+		valueField = pf.field.MapValue()
+	}
+	if enum := valueField.Enum(); enum != nil && !IsWellKnownType(enum) {
+		names := make([]string, 0, enum.Values().Len())
+		rangeEnumValues(enum, func(v protoreflect.EnumValueDescriptor, _ bool) {
+			if !dropUnspecified || !isUnspecifiedValue(v) {
+				names = append(names, strconv.Quote(string(v.Name())))
+			}
+		})
+		reference = target.union(reference, "Literal["+strings.Join(names, ", ")+"]")
+	}
+	switch {
+	case pf.fieldType.IsMap:
+		reference = target.generic("dict") + "[str, " + reference + "]"
+	case pf.fieldType.IsList:
+		reference = target.generic("list") + "[" + reference + "]"
+	}
+	if pf.isOptional {
+		reference = target.optional(reference)
+	}
+	return reference
+}
@@ -9,6 +9,9 @@ import (
 type Type struct {
 	IsNamed bool
 	Name    string
+	// QualifiedName prefixes Name with the classes enclosing a nested
+	// message or enum, for references made outside of them.
+	QualifiedName string
 
 	IsList     bool
 	IsMap      bool
@@ -29,6 +32,19 @@ func (t Type) Reference(isUUID bool) string {
 	}
 }
 
+// Qualified returns the type with nested names replaced by their qualified
+// names.
+func (t Type) Qualified() Type {
+	if t.Underlying != nil {
+		underlying := t.Underlying.Qualified()
+		t.Underlying = &underlying
+	}
+	if t.QualifiedName != "" {
+		t.Name = t.QualifiedName
+	}
+	return t
+}
+
 func typeFromField(pkg protoreflect.FullName, field protoreflect.FieldDescriptor) Type {
 	switch {
 	case field.IsMap():
@@ -68,14 +84,14 @@ func namedTypeFromField(pkg protoreflect.FullName, field protoreflect.FieldDescr
 		return Type{IsNamed: true, Name: "int"}
 	case protoreflect.FloatKind, protoreflect.DoubleKind:
 		return Type{IsNamed: true, Name: "float"}
-	case protoreflect.MessageKind:
+	case protoreflect.MessageKind, protoreflect.GroupKind:
 		return typeFromMessage(pkg, field.Message())
 	case protoreflect.EnumKind:
 		desc := field.Enum()
 		if wkt, ok := WellKnownType(field.Enum()); ok {
 			return Type{IsNamed: true, Name: wkt.Name()}
 		}
-		return Type{IsNamed: true, Name: string(desc.Name())}
+		return Type{IsNamed: true, Name: string(desc.Name()), QualifiedName: qualifiedTypeName(desc)}
 	default:
 		panic(fmt.Sprintf("unknown field kind: %s", field.Kind()))
 	}
@@ -85,5 +101,13 @@ func typeFromMessage(pkg protoreflect.FullName, message protoreflect.MessageDesc
 	if wkt, ok := WellKnownType(message); ok {
 		return Type{IsNamed: true, Name: wkt.Name()}
 	}
-	return Type{IsNamed: true, Name: string(message.Name())}
+	return Type{IsNamed: true, Name: string(message.Name()), QualifiedName: qualifiedTypeName(message)}
+}
+
+func qualifiedTypeName(desc protoreflect.Descriptor) string {
+	name := string(desc.Name())
+	if parent, ok := desc.Parent().(protoreflect.MessageDescriptor); ok {
+		return qualifiedTypeName(parent) + "." + name
+	}
+	return name
 }
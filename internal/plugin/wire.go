@@ -0,0 +1,111 @@
+package plugin
+
+import (
+	_ "embed"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cortea-ai/protoc-gen-pydantic/internal/codegen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// wireRuntime implements the protobuf binary format for the models, driven
+// by their __proto_fields__.
+//
+//go:embed wire.py
+var wireRuntime string
+
+func generateWireRuntime(f *codegen.File, target pythonTarget) {
+	f.P(strings.TrimSuffix(target.rewrite(wireRuntime), "\n"))
+	f.P()
+	f.P()
+}
+
+// wireKind returns the kind of a value in __proto_fields__: the proto kind,
+// or timestamp and duration for those well known types.
+func wireKind(field protoreflect.FieldDescriptor) string {
+	if field.Message() != nil {
+		if wkt, ok := WellKnownType(field.Message()); ok {
+			switch wkt {
+			case WellKnownTimestamp:
+				return "timestamp"
+			case WellKnownDuration:
+				return "duration"
+			}
+		}
+	}
+	return field.Kind().String()
+}
+
+// wireTarget returns the class name of the enum or message of a value.
+func (d descriptorGenerator) wireTarget(field protoreflect.FieldDescriptor) string {
+	switch kind := wireKind(field); kind {
+	case "enum", "message", "group":
+		return strconv.Quote(namedTypeFromField(d.pkg, field).Qualified().Name)
+	}
+	return ""
+}
+
+// protoFieldSpec renders the _ProtoField describing the encoding of a field.
+func (d descriptorGenerator) protoFieldSpec(field protoreflect.FieldDescriptor) string {
+	value := field
+	if field.IsMap() {
+		value = field.MapValue()
+	}
+	args := []string{
+		strconv.Quote(string(field.Name())),
+		strconv.Itoa(int(field.Number())),
+		strconv.Quote(wireKind(value)),
+	}
+	switch {
+	case field.IsMap():
+		args = append(args, `label="map"`)
+	case field.IsList():
+		args = append(args, `label="repeated"`)
+	case hasExplicitPresence(field) || isOneOfField(field) || field.Message() != nil ||
+		field.Cardinality() == protoreflect.Required:
+		args = append(args, "presence=True")
+	}
+	if field.IsPacked() {
+		args = append(args, "packed=True")
+	}
+	if isOneOfField(field) {
+		args = append(args, "oneof="+strconv.Quote(string(field.ContainingOneof().Name())))
+	}
+	if target := d.wireTarget(value); target != "" {
+		args = append(args, "target="+target)
+	}
+	if field.IsMap() {
+		args = append(args, "key_kind="+strconv.Quote(wireKind(field.MapKey())))
+	}
+	return "_ProtoField(" + strings.Join(args, ", ") + "),"
+}
+
+// generateWireFormat emits the field numbers and wire types of a model with
+// its to_bytes and from_bytes methods.
+func (d descriptorGenerator) generateWireFormat(f *codegen.File, message protoreflect.MessageDescriptor) {
+	if !boolParam(d.params, "wire_format") {
+		return
+	}
+	fields := make([]protoreflect.FieldDescriptor, 0, message.Fields().Len())
+	rangeFields(message, func(field protoreflect.FieldDescriptor) {
+		fields = append(fields, field)
+	})
+	sort.SliceStable(fields, func(i, j int) bool {
+		return fields[i].Number() < fields[j].Number()
+	})
+	f.P("")
+	f.P(t(d.indent+2), "__proto_fields__ = (")
+	for _, field := range fields {
+		f.P(t(d.indent+4), d.protoFieldSpec(field))
+	}
+	f.P(t(d.indent+2), ")")
+	f.P("")
+	f.P(t(d.indent+2), "def to_bytes(self) -> bytes:")
+	f.P(t(d.indent+4), "return _encode_message(self)")
+	f.P("")
+	f.P(t(d.indent+2), "@classmethod")
+	f.P(t(d.indent+2), "def from_bytes(cls, data: bytes) -> Self:")
+	f.P(t(d.indent+4), "return _decode_message(cls, data)")
+}
@@ -0,0 +1,383 @@
+class _ProtoField(NamedTuple):
+    name: str
+    number: int
+    kind: str
+    label: str = "singular"
+    presence: bool = False
+    packed: bool = False
+    oneof: Optional[str] = None
+    target: Optional[str] = None
+    key_kind: Optional[str] = None
+
+
+_VARINT, _I64, _LEN, _SGROUP, _EGROUP, _I32 = 0, 1, 2, 3, 4, 5
+_EPOCH = datetime.datetime(1970, 1, 1, tzinfo=datetime.timezone.utc)
+_FIXED = {
+    "fixed32": ("<I", _I32),
+    "sfixed32": ("<i", _I32),
+    "float": ("<f", _I32),
+    "fixed64": ("<Q", _I64),
+    "sfixed64": ("<q", _I64),
+    "double": ("<d", _I64),
+}
+_VARINT_KINDS = frozenset({"int32", "int64", "uint32", "uint64", "sint32", "sint64", "bool", "enum"})
+_PACKABLE_KINDS = _VARINT_KINDS | frozenset(_FIXED)
+
+
+def _resolve(owner: type, name: str):
+    # targets are named in the module of the model declaring the field, which
+    # imports the models of other modules it references
+    target = vars(sys.modules[owner.__module__])
+    for part in name.split("."):
+        target = target[part] if isinstance(target, dict) else getattr(target, part)
+    return target
+
+
+def _write_varint(out: bytearray, v: int):
+    v &= 0xFFFFFFFFFFFFFFFF
+    while v >= 0x80:
+        out.append((v & 0x7F) | 0x80)
+        v >>= 7
+    out.append(v)
+
+
+def _read_varint(data: bytes, pos: int) -> tuple[int, int]:
+    result = shift = 0
+    while True:
+        if pos >= len(data):
+            raise ValueError("truncated varint")
+        b = data[pos]
+        pos += 1
+        result |= (b & 0x7F) << shift
+        if not b & 0x80:
+            return result, pos
+        shift += 7
+        if shift >= 70:
+            raise ValueError("varint too long")
+
+
+def _write_tag(out: bytearray, number: int, wire_type: int):
+    _write_varint(out, number << 3 | wire_type)
+
+
+def _is_zero(v) -> bool:
+    # enums of other packages derive from the ProtoEnum of their own module
+    return getattr(v, "number", v) in ("", 0, False)
+
+
+def _wire_type(kind: str) -> int:
+    if kind in _FIXED:
+        return _FIXED[kind][1]
+    if kind in _VARINT_KINDS:
+        return _VARINT
+    if kind == "group":
+        return _SGROUP
+    return _LEN
+
+
+def _encode_scalar(out: bytearray, kind: str, v):
+    if kind in _FIXED:
+        out += struct.pack(_FIXED[kind][0], v)
+    elif kind == "sint32":
+        _write_varint(out, ((v << 1) ^ (v >> 31)) & 0xFFFFFFFF)
+    elif kind == "sint64":
+        _write_varint(out, (v << 1) ^ (v >> 63))
+    elif kind == "enum":
+        _write_varint(out, v.number)
+    elif kind in _VARINT_KINDS:
+        _write_varint(out, int(v))
+    else:
+        if kind == "string":
+            raw = str(v).encode("utf-8")
+        elif kind == "bytes":
+            raw = v.encode("utf-8", "surrogateescape")
+        elif kind == "timestamp":
+            raw = _encode_timestamp(v)
+        elif kind == "duration":
+            raw = _encode_duration(v)
+        else:
+            raw = _encode_message(v)
+        _write_varint(out, len(raw))
+        out += raw
+
+
+def _encode_seconds(seconds: int, nanos: int) -> bytes:
+    out = bytearray()
+    if seconds:
+        _write_tag(out, 1, _VARINT)
+        _write_varint(out, seconds)
+    if nanos:
+        _write_tag(out, 2, _VARINT)
+        _write_varint(out, nanos)
+    return bytes(out)
+
+
+def _micros(delta: datetime.timedelta) -> int:
+    return (delta.days * 86400 + delta.seconds) * 1000000 + delta.microseconds
+
+
+def _encode_timestamp(v: datetime.datetime) -> bytes:
+    if v.tzinfo is None:
+        v = v.replace(tzinfo=datetime.timezone.utc)
+    # nanos of a timestamp are never negative
+    seconds, micros = divmod(_micros(v - _EPOCH), 1000000)
+    return _encode_seconds(seconds, micros * 1000)
+
+
+def _encode_duration(v: datetime.timedelta) -> bytes:
+    # seconds and nanos of a duration share its sign
+    micros = _micros(v)
+    sign = -1 if micros < 0 else 1
+    seconds, micros = divmod(abs(micros), 1000000)
+    return _encode_seconds(sign * seconds, sign * micros * 1000)
+
+
+def _encode_field(out: bytearray, number: int, kind: str, v):
+    if kind == "group":
+        _write_tag(out, number, _SGROUP)
+        out += _encode_message(v)
+        _write_tag(out, number, _EGROUP)
+        return
+    _write_tag(out, number, _wire_type(kind))
+    _encode_scalar(out, kind, v)
+
+
+def _encode_message(model: BaseModel) -> bytes:
+    out = bytearray()
+    for field in type(model).__proto_fields__:
+        value = getattr(model, field.name)
+        if value is None:
+            continue
+        if field.label == "map":
+            for k, v in value.items():
+                if field.key_kind == "bool":
+                    k = k in ("true", "True")
+                elif field.key_kind != "string":
+                    k = int(k)
+                entry = bytearray()
+                _encode_field(entry, 1, field.key_kind, k)
+                if v is not None:
+                    _encode_field(entry, 2, field.kind, v)
+                _write_tag(out, field.number, _LEN)
+                _write_varint(out, len(entry))
+                out += entry
+        elif field.label == "repeated":
+            if field.packed and value:
+                packed = bytearray()
+                for v in value:
+                    _encode_scalar(packed, field.kind, v)
+                _write_tag(out, field.number, _LEN)
+                _write_varint(out, len(packed))
+                out += packed
+            else:
+                for v in value:
+                    _encode_field(out, field.number, field.kind, v)
+        elif field.presence or not _is_zero(value):
+            _encode_field(out, field.number, field.kind, value)
+    return bytes(out)
+
+
+def _skip_field(data: bytes, pos: int, number: int, wire_type: int) -> int:
+    if wire_type == _VARINT:
+        return _read_varint(data, pos)[1]
+    if wire_type == _I64:
+        return pos + 8
+    if wire_type == _I32:
+        return pos + 4
+    if wire_type == _LEN:
+        length, pos = _read_varint(data, pos)
+        return pos + length
+    if wire_type == _SGROUP:
+        while True:
+            tag, pos = _read_varint(data, pos)
+            if tag & 7 == _EGROUP:
+                if tag >> 3 != number:
+                    raise ValueError("mismatched end group")
+                return pos
+            pos = _skip_field(data, pos, tag >> 3, tag & 7)
+    raise ValueError(f"invalid wire type {wire_type}")
+
+
+def _read_group(data: bytes, pos: int, number: int) -> tuple[bytes, int]:
+    start = pos
+    while True:
+        tag, end = _read_varint(data, pos)
+        if tag & 7 == _EGROUP and tag >> 3 == number:
+            return data[start:pos], end
+        pos = _skip_field(data, end, tag >> 3, tag & 7)
+
+
+def _signed(v: int, bits: int) -> int:
+    v &= (1 << bits) - 1
+    return v - (1 << bits) if v >> (bits - 1) else v
+
+
+def _decode_varint(kind: str, v: int):
+    if kind in ("int32", "enum"):
+        return _signed(v, 32)
+    if kind == "int64":
+        return _signed(v, 64)
+    if kind == "uint32":
+        return v & 0xFFFFFFFF
+    if kind == "sint32":
+        v &= 0xFFFFFFFF
+        return (v >> 1) ^ -(v & 1)
+    if kind == "sint64":
+        return (v >> 1) ^ -(v & 1)
+    if kind == "bool":
+        return v != 0
+    return v
+
+
+def _decode_seconds(data: bytes) -> datetime.timedelta:
+    values = _parse(data, {1: "int64", 2: "int32"})
+    return datetime.timedelta(seconds=values.get(1, 0), microseconds=int(values.get(2, 0) / 1000))
+
+
+def _parse(data: bytes, kinds: dict[int, str]) -> dict[int, int]:
+    values = {}
+    pos = 0
+    while pos < len(data):
+        tag, pos = _read_varint(data, pos)
+        number, wire_type = tag >> 3, tag & 7
+        if wire_type == _VARINT and number in kinds:
+            v, pos = _read_varint(data, pos)
+            values[number] = _decode_varint(kinds[number], v)
+        else:
+            pos = _skip_field(data, pos, number, wire_type)
+    return values
+
+
+def _decode_value(owner: type, kind: str, target: Optional[str], raw):
+    if kind == "enum":
+        try:
+            return _resolve(owner, target).from_number(raw)
+        except ValueError:
+            # unknown values of closed and open enums have no member
+            return None
+    if kind == "string":
+        return raw.decode("utf-8")
+    if kind == "bytes":
+        return raw.decode("utf-8", "surrogateescape")
+    if kind == "timestamp":
+        return _EPOCH + _decode_seconds(raw)
+    if kind == "duration":
+        return _decode_seconds(raw)
+    if kind in ("message", "group"):
+        return _decode_message(_resolve(owner, target), raw)
+    return raw
+
+
+def _read_value(data: bytes, pos: int, kind: str, number: int, wire_type: int):
+    if wire_type == _VARINT:
+        v, pos = _read_varint(data, pos)
+        return _decode_varint(kind, v), pos
+    if wire_type in (_I32, _I64):
+        fmt = _FIXED[kind][0]
+        size = struct.calcsize(fmt)
+        if pos + size > len(data):
+            raise ValueError("truncated fixed value")
+        return struct.unpack_from(fmt, data, pos)[0], pos + size
+    if wire_type == _SGROUP:
+        return _read_group(data, pos, number)
+    length, pos = _read_varint(data, pos)
+    if pos + length > len(data):
+        raise ValueError("truncated length delimited value")
+    return data[pos:pos + length], pos + length
+
+
+def _decode_map_entry(owner: type, field: _ProtoField, raw: bytes):
+    key = value = None
+    pos = 0
+    while pos < len(raw):
+        tag, pos = _read_varint(raw, pos)
+        number, wire_type = tag >> 3, tag & 7
+        if number == 1:
+            key, pos = _read_value(raw, pos, field.key_kind, number, wire_type)
+        elif number == 2:
+            value, pos = _read_value(raw, pos, field.kind, number, wire_type)
+        else:
+            pos = _skip_field(raw, pos, number, wire_type)
+    if field.key_kind == "string":
+        key = (key or b"").decode("utf-8")
+    elif field.key_kind == "bool":
+        key = "true" if key else "false"
+    else:
+        key = str(key or 0)
+    if value is None:
+        value = b"" if field.kind in ("string", "bytes", "message", "timestamp", "duration") else 0
+    return key, _decode_value(owner, field.kind, field.target, value)
+
+
+def _zero_value(owner: type, field: _ProtoField):
+    if field.label == "repeated":
+        return []
+    if field.label == "map":
+        return {}
+    if field.kind in ("string", "bytes"):
+        return ""
+    if field.kind == "bool":
+        return False
+    if field.kind in ("float", "double"):
+        return 0.0
+    if field.kind == "enum":
+        return _decode_value(owner, "enum", field.target, 0)
+    if field.kind == "timestamp":
+        return _EPOCH
+    if field.kind == "duration":
+        return datetime.timedelta()
+    if field.kind in ("message", "group"):
+        return _decode_message(_resolve(owner, field.target), b"")
+    return 0
+
+
+def _decode_message(cls, data: bytes):
+    fields = {field.number: field for field in cls.__proto_fields__}
+    values = {}
+    pending = {}
+    pos = 0
+    while pos < len(data):
+        tag, pos = _read_varint(data, pos)
+        number, wire_type = tag >> 3, tag & 7
+        field = fields.get(number)
+        if field is None:
+            pos = _skip_field(data, pos, number, wire_type)
+            continue
+        if field.label == "repeated" and wire_type == _LEN and field.kind in _PACKABLE_KINDS:
+            raw, pos = _read_value(data, pos, field.kind, number, wire_type)
+            inner = 0
+            while inner < len(raw):
+                v, inner = _read_value(raw, inner, field.kind, number, _wire_type(field.kind))
+                v = _decode_value(cls, field.kind, field.target, v)
+                if v is not None:
+                    values.setdefault(field.name, []).append(v)
+            continue
+        raw, pos = _read_value(data, pos, field.kind, number, wire_type)
+        if field.oneof is not None:
+            for other in cls.__proto_fields__:
+                if other.oneof == field.oneof and other.name != field.name:
+                    values.pop(other.name, None)
+                    pending.pop(other.name, None)
+        if field.label == "map":
+            key, value = _decode_map_entry(cls, field, raw)
+            values.setdefault(field.name, {})[key] = value
+        elif field.label == "repeated":
+            v = _decode_value(cls, field.kind, field.target, raw)
+            if v is not None:
+                values.setdefault(field.name, []).append(v)
+        elif field.kind in ("message", "group", "timestamp", "duration"):
+            # repeated occurrences of a message merge
+            pending[field.name] = pending.get(field.name, b"") + raw
+        else:
+            v = _decode_value(cls, field.kind, field.target, raw)
+            if v is not None:
+                values[field.name] = v
+    for name, raw in pending.items():
+        field = next(field for field in cls.__proto_fields__ if field.name == name)
+        values[name] = _decode_value(cls, field.kind, field.target, raw)
+    for field in cls.__proto_fields__:
+        if field.name not in values and cls.model_fields[field.name].is_required():
+            value = _zero_value(cls, field)
+            if value is not None:
+                values[field.name] = value
+    return cls.model_validate(values)
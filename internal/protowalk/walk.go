@@ -11,6 +11,23 @@ func WalkFiles(files []protoreflect.FileDescriptor, f WalkFunc) {
 	w.walkFiles(files, f)
 }
 
+// WalkDescriptors walks messages and enums, with their nested types and the
+// types their fields reference, and services with the request and response
+// messages of their methods.
+func WalkDescriptors(descs []protoreflect.Descriptor, f WalkFunc) {
+	var w walker
+	for _, desc := range descs {
+		switch desc := desc.(type) {
+		case protoreflect.MessageDescriptor:
+			w.walkMessage(desc, f)
+		case protoreflect.EnumDescriptor:
+			w.walkEnum(desc, f)
+		case protoreflect.ServiceDescriptor:
+			w.walkService(desc, f)
+		}
+	}
+}
+
 type walker struct {
 	seen map[string]struct{}
 }
@@ -94,3 +111,16 @@ func (w *walker) walkField(field protoreflect.FieldDescriptor, f WalkFunc) {
 		}
 	}
 }
+
+func (w *walker) walkService(service protoreflect.ServiceDescriptor, f WalkFunc) {
+	if w.enter(string(service.FullName())) {
+		if !f(service) {
+			return
+		}
+		for i := 0; i < service.Methods().Len(); i++ {
+			method := service.Methods().Get(i)
+			w.walkMessage(method.Input(), f)
+			w.walkMessage(method.Output(), f)
+		}
+	}
+}